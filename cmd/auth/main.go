@@ -2,10 +2,14 @@ package main
 
 import (
 	"log/slog"
+	"os"
+	"os/signal"
 	"strconv"
+	"syscall"
 
 	"github.com/kms-qwe/DAEC/internal/app/auth"
 	"github.com/kms-qwe/DAEC/internal/config"
+	"github.com/kms-qwe/DAEC/internal/lib/jwt"
 	"github.com/kms-qwe/DAEC/internal/lib/logger/setup"
 	"github.com/kms-qwe/DAEC/internal/lib/logger/sl"
 	"github.com/kms-qwe/DAEC/internal/storage/sqlite"
@@ -27,7 +31,35 @@ func main() {
 		panic("auth can't connect ot db")
 	}
 	log.Info("auth connect to db")
-	app := auth.NewServer(log, port, cfg.TokenTTL, authStorage)
-	app.MustRun()
 
+	tokens, err := loadKeys(cfg.JWT)
+	if err != nil {
+		log.Error("auth can't load jwt keys", sl.Err(err))
+		panic("auth can't load jwt keys")
+	}
+	log.Info("jwt keys loaded", slog.String("signing_kid", cfg.JWT.SigningKID))
+
+	app := auth.NewServer(log, port, cfg.TokenTTL, cfg.RefreshTTL, cfg.WatchInterval, tokens, authStorage)
+	go app.MustRun()
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGTERM, syscall.SIGINT)
+
+	sign := <-stop
+	log.Info("stopping auth", slog.String("signal", sign.String()))
+
+	app.Stop(cfg.ShutdownTimeout)
+	log.Info("auth stopped")
+}
+
+func loadKeys(cfg config.JWTConfig) (*jwt.KeySet, error) {
+	keys := make([]*jwt.Key, 0, len(cfg.Keys))
+	for _, k := range cfg.Keys {
+		key, err := jwt.LoadKey(k.KID, k.Alg, k.Secret, k.PrivateKeyFile, k.PublicKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return jwt.NewKeySet(cfg.SigningKID, keys...)
 }
@@ -1,15 +1,22 @@
 package main
 
 import (
+	"context"
 	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	orchApp "github.com/kms-qwe/DAEC/internal/app/orch"
 	"github.com/kms-qwe/DAEC/internal/config"
+	"github.com/kms-qwe/DAEC/internal/expr"
 	"github.com/kms-qwe/DAEC/internal/grpc/orch"
 	"github.com/kms-qwe/DAEC/internal/lib/logger/setup"
 	"github.com/kms-qwe/DAEC/internal/lib/logger/sl"
 	daecv1 "github.com/kms-qwe/DAEC/internal/protos/gen/go/daec"
 	"github.com/kms-qwe/DAEC/internal/storage/sqlite"
+	"github.com/kms-qwe/DAEC/internal/webhook"
 )
 
 func main() {
@@ -28,14 +35,54 @@ func main() {
 	}
 	log.Info("orch connect to db")
 	tP := &orch.TaskPuller{
-		Log:         log,
-		ExpStrg:     orchStorage,
-		ChToAgent:   make(chan *daecv1.TaskResponse),
-		ChFromAgent: make(chan *daecv1.ResultRequest),
-		ExprID:      0,
-		Expr:        "",
+		Log:          log,
+		ExpStrg:      orchStorage,
+		ChToAgent:    make(chan *daecv1.TaskResponse),
+		ChFromAgent:  make(chan *orch.Result),
+		ChSession:    make(chan orch.SessionEvent),
+		ChAdmin:      make(chan chan []*daecv1.AgentInfo),
+		MaxInFlight:  cfg.MaxInFlight,
+		PollInterval: cfg.PollInterval,
+		OpTimes: map[string]time.Duration{
+			"+":  cfg.Addition,
+			"-":  cfg.Subtraction,
+			"*":  cfg.Multiplication,
+			"/":  cfg.Division,
+			"//": cfg.IntDivision,
+			"%":  cfg.Modulo,
+			"^":  cfg.Exponentiation,
+		},
+		LeaseGrace:   cfg.LeaseGrace,
+		AgentTimeout: cfg.AgentTimeout,
+		DrainTimeout: cfg.ShutdownTimeout,
+		Stopped:      make(chan struct{}),
+	}
+	for _, name := range expr.FuncNames() {
+		tP.OpTimes[name] = cfg.FunctionTime(name)
 	}
 	application := orchApp.New(log, tP, cfg.GRPC.Port)
-	application.MustRun()
+	go application.MustRun()
+
+	webhookCtx, stopWebhooks := context.WithCancel(context.Background())
+	webhooksDone := make(chan struct{})
+	if cfg.Webhook.Secret != "" {
+		go func() {
+			webhook.New(log, orchStorage, cfg.Webhook).Run(webhookCtx)
+			close(webhooksDone)
+		}()
+	} else {
+		log.Warn("webhook secret is not set, webhooks are not delivered")
+		close(webhooksDone)
+	}
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGTERM, syscall.SIGINT)
+
+	sign := <-stop
+	log.Info("stopping orchestrator", slog.String("signal", sign.String()))
 
+	application.Stop()
+	stopWebhooks()
+	<-webhooksDone
+	log.Info("orchestrator stopped")
 }
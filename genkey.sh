@@ -0,0 +1,6 @@
+#!/bin/sh
+# ключ подписи JWT для config/local.yaml - только для локальной разработки, в git не хранится
+KEY=./config/keys/local-ed25519.pem
+[ -f "$KEY" ] && exit 0
+mkdir -p ./config/keys
+openssl genpkey -algorithm ed25519 -out "$KEY"
@@ -7,6 +7,7 @@ require (
 	github.com/golang-jwt/jwt/v5 v5.2.1
 	github.com/ilyakaznacheev/cleanenv v1.5.0
 	github.com/mattn/go-sqlite3 v1.14.22
+	golang.org/x/crypto v0.23.0
 	golang.org/x/exp v0.0.0-20240716175740-e3f259677ff7
 	google.golang.org/grpc v1.65.0
 	google.golang.org/protobuf v1.34.2
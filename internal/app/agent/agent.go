@@ -0,0 +1,313 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/kms-qwe/DAEC/internal/config"
+	"github.com/kms-qwe/DAEC/internal/lib/logger/sl"
+	pb "github.com/kms-qwe/DAEC/internal/protos/gen/go/daec"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// reconnectDelay - пауза перед повторным открытием сессии после обрыва
+const reconnectDelay = time.Second
+
+type Agent struct {
+	log      *slog.Logger
+	cfg      *config.Config
+	addr     string
+	id       string
+	hostname string
+	slots    int
+	// busy - сколько задач сейчас вычисляется, сообщается в heartbeat
+	busy atomic.Int32
+
+	// unsent - результаты, которые не удалось отправить из-за обрыва сессии;
+	// досылаются в следующей сессии, оркестратор примет их по сохраненной аренде
+	mu     sync.Mutex
+	unsent []*pb.ResultRequest
+
+	// ctx отменяется в Stop, done закрывается, когда MustRun завершился
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func New(log *slog.Logger, cfg *config.Config) *Agent {
+	host, _ := os.Hostname()
+	id := cfg.Agent.ID
+	if id == "" {
+		id = fmt.Sprintf("%s-%d", host, os.Getpid())
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Agent{
+		log:      log,
+		cfg:      cfg,
+		addr:     cfg.Agent.OrchAddress,
+		id:       id,
+		hostname: host,
+		slots:    cfg.ComputingPower,
+		ctx:      ctx,
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+}
+
+// MustRun - подключается к оркестратору и держит сессию, переоткрывая ее при обрыве
+func (a *Agent) MustRun() {
+	const op = "agent.MustRun"
+	log := a.log.With(slog.String("op", op), slog.String("agent", a.id))
+
+	conn, err := grpc.NewClient(a.addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		log.Error("can't create grpc client", sl.Err(err))
+		panic("can't create grpc client")
+	}
+	defer conn.Close()
+
+	defer close(a.done)
+
+	client := pb.NewOrchServiceClient(conn)
+	for {
+		err := a.session(a.ctx, client)
+		if a.ctx.Err() != nil {
+			log.Info("агент остановлен", sl.Err(err))
+			return
+		}
+		log.Info("сессия завершена, переподключение", sl.Err(err))
+		select {
+		case <-time.After(reconnectDelay):
+		case <-a.ctx.Done():
+			return
+		}
+	}
+}
+
+// Stop - новые задачи не начинаются, начатые досчитываются и их результаты
+// отправляются, затем сессия закрывается. Задачи, которые агент не начал,
+// оркестратор вернет в очередь при закрытии сессии
+func (a *Agent) Stop() {
+	a.cancel()
+	<-a.done
+}
+
+// session - одна сессия с оркестратором: slots воркеров вычисляют задачи из потока,
+// после каждого результата агент сообщает об освободившемся месте.
+// Отмена stop завершает сессию мягко: поток остается открытым, пока
+// не отправлены результаты начатых задач
+func (a *Agent) session(stop context.Context, client pb.OrchServiceClient) error {
+	const op = "agent.session"
+	log := a.log.With(slog.String("op", op))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stream, err := client.Connect(ctx)
+	if err != nil {
+		return fmt.Errorf("can't open session: %w", err)
+	}
+
+	// grpc не допускает одновременных Send из разных горутин
+	var mu sync.Mutex
+	send := func(msg *pb.AgentMessage) error {
+		mu.Lock()
+		defer mu.Unlock()
+		return stream.Send(msg)
+	}
+
+	hello := &pb.Hello{
+		AgentId:    a.id,
+		Hostname:   a.hostname,
+		Slots:      int32(a.slots),
+		Operations: operations,
+	}
+	if err := send(&pb.AgentMessage{Msg: &pb.AgentMessage_Hello{Hello: hello}}); err != nil {
+		return fmt.Errorf("can't send hello: %w", err)
+	}
+	first, err := stream.Recv()
+	if err != nil {
+		return fmt.Errorf("can't receive welcome: %w", err)
+	}
+	log = log.With(slog.String("session", first.GetWelcome().GetSessionId()))
+	log.Info("сессия открыта", slog.Int("slots", a.slots))
+	a.resend(log, send)
+
+	// воркеры завершаются при обрыве сессии (ctx) или остановке агента (stop)
+	workCtx, stopWork := context.WithCancel(ctx)
+	defer stopWork()
+	go func() {
+		select {
+		case <-stop.Done():
+			stopWork()
+		case <-workCtx.Done():
+		}
+	}()
+
+	tasks := make(chan *pb.TaskResponse, a.slots)
+	var wg sync.WaitGroup
+	wg.Add(a.slots)
+	for range a.slots {
+		go func() {
+			defer wg.Done()
+			a.worker(workCtx, log, tasks, send)
+		}()
+	}
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+	go a.heartbeat(ctx, log, send)
+
+	recvErr := make(chan error, 1)
+	go func() {
+		recvErr <- a.recv(ctx, log, stream, tasks)
+	}()
+
+	select {
+	case err := <-recvErr:
+		return err
+	case <-stop.Done():
+	}
+
+	log.Info("остановка: ждем начатые задачи")
+	deadline := time.After(a.cfg.ShutdownTimeout)
+	finished := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+	case <-deadline:
+		log.Info("остановка: начатые задачи не завершились вовремя")
+		return stop.Err()
+	}
+
+	// оркестратор закрывает поток, обработав все отправленные результаты
+	mu.Lock()
+	err = stream.CloseSend()
+	mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("can't close session: %w", err)
+	}
+	select {
+	case <-recvErr:
+	case <-deadline:
+		log.Info("остановка: оркестратор не закрыл сессию вовремя")
+	}
+	return stop.Err()
+}
+
+// recv - читает задачи и подтверждения результатов из потока оркестратора
+func (a *Agent) recv(ctx context.Context, log *slog.Logger, stream pb.OrchService_ConnectClient, tasks chan<- *pb.TaskResponse) error {
+	for {
+		msg, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return fmt.Errorf("session closed by orchestrator")
+		}
+		if err != nil {
+			return err
+		}
+
+		switch m := msg.GetMsg().(type) {
+		case *pb.OrchMessage_Task:
+			log.Debug("получена задача", slog.Any("task", m.Task))
+			select {
+			case tasks <- m.Task:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		case *pb.OrchMessage_Ack:
+			if m.Ack.GetCode() != "" {
+				log.Info("результат не принят", slog.String("code", m.Ack.GetCode()), slog.String("message", m.Ack.GetMessage()))
+			}
+		}
+	}
+}
+
+func (a *Agent) worker(ctx context.Context, log *slog.Logger, tasks <-chan *pb.TaskResponse, send func(*pb.AgentMessage) error) {
+	for {
+		var task *pb.TaskResponse
+		select {
+		case task = <-tasks:
+		case <-ctx.Done():
+			return
+		}
+		if ctx.Err() != nil {
+			// сессия закрыта или агент останавливается - задачу вернет в очередь оркестратор
+			return
+		}
+
+		resultRequest := &pb.ResultRequest{
+			ExprId:     task.ExprId,
+			NodeId:     task.NodeId,
+			Attempt:    task.Attempt,
+			LeaseToken: task.LeaseToken,
+		}
+		a.busy.Add(1)
+		resultRequest.Result, resultRequest.Error = execute(a.cfg, task)
+		a.busy.Add(-1)
+		if resultRequest.Error != nil {
+			log.Info("task failed", slog.Any("error", resultRequest.Error))
+		}
+
+		if err := send(&pb.AgentMessage{Msg: &pb.AgentMessage_Result{Result: resultRequest}}); err != nil {
+			log.Info("could not send result, will resend after reconnect", sl.Err(err))
+			a.mu.Lock()
+			a.unsent = append(a.unsent, resultRequest)
+			a.mu.Unlock()
+			continue
+		}
+		if ctx.Err() != nil {
+			// при остановке новых задач не просим
+			continue
+		}
+		if err := send(&pb.AgentMessage{Msg: &pb.AgentMessage_Ready{Ready: &pb.Ready{Slots: 1}}}); err != nil {
+			log.Info("could not send ready", sl.Err(err))
+		}
+	}
+}
+
+// resend - досылает результаты, не отправленные в прошлых сессиях
+func (a *Agent) resend(log *slog.Logger, send func(*pb.AgentMessage) error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for i, r := range a.unsent {
+		if err := send(&pb.AgentMessage{Msg: &pb.AgentMessage_Result{Result: r}}); err != nil {
+			log.Info("could not resend result", sl.Err(err))
+			a.unsent = a.unsent[i:]
+			return
+		}
+	}
+	if len(a.unsent) > 0 {
+		log.Info("досланы результаты прошлой сессии", slog.Int("count", len(a.unsent)))
+	}
+	a.unsent = nil
+}
+
+// heartbeat - сообщает оркестратору, что агент жив, пока открыта сессия
+func (a *Agent) heartbeat(ctx context.Context, log *slog.Logger, send func(*pb.AgentMessage) error) {
+	ticker := time.NewTicker(a.cfg.Agent.HeartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			hb := &pb.Heartbeat{Busy: a.busy.Load()}
+			if err := send(&pb.AgentMessage{Msg: &pb.AgentMessage_Heartbeat{Heartbeat: hb}}); err != nil {
+				log.Info("could not send heartbeat", sl.Err(err))
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
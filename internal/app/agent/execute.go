@@ -0,0 +1,128 @@
+package agent
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/kms-qwe/DAEC/internal/config"
+	"github.com/kms-qwe/DAEC/internal/expr"
+	pb "github.com/kms-qwe/DAEC/internal/protos/gen/go/daec"
+)
+
+// operations - операции, которые умеет execute; сообщаются оркестратору при регистрации
+var operations = append([]string{"+", "-", "*", "/", "//", "%", "^"}, expr.FuncNames()...)
+
+const (
+	errDivisionByZero       = "division_by_zero"
+	errOverflow             = "overflow"
+	errDomain               = "domain_error"
+	errUnsupportedOperation = "unsupported_operation"
+)
+
+// execute - вычисляет операцию задачи, имитируя ее длительность из конфига
+func execute(cfg *config.Config, task *pb.TaskResponse) (float64, *pb.TaskError) {
+	var res float64
+	switch task.Operation {
+	case "+":
+		res = task.Arg1 + task.Arg2
+		time.Sleep(cfg.Addition)
+	case "-":
+		res = task.Arg1 - task.Arg2
+		time.Sleep(cfg.Subtraction)
+	case "*":
+		res = task.Arg1 * task.Arg2
+		time.Sleep(cfg.Multiplication)
+	case "/":
+		time.Sleep(cfg.Division)
+		if task.Arg2 == 0 {
+			return 0, &pb.TaskError{
+				Code:    errDivisionByZero,
+				Message: fmt.Sprintf("%g / %g", task.Arg1, task.Arg2),
+			}
+		}
+		res = task.Arg1 / task.Arg2
+	case "//":
+		time.Sleep(cfg.IntDivision)
+		if task.Arg2 == 0 {
+			return 0, &pb.TaskError{
+				Code:    errDivisionByZero,
+				Message: fmt.Sprintf("%g // %g", task.Arg1, task.Arg2),
+			}
+		}
+		res = math.Floor(task.Arg1 / task.Arg2)
+	case "%":
+		time.Sleep(cfg.Modulo)
+		if task.Arg2 == 0 {
+			return 0, &pb.TaskError{
+				Code:    errDivisionByZero,
+				Message: fmt.Sprintf("%g %% %g", task.Arg1, task.Arg2),
+			}
+		}
+		res = mod(task.Arg1, task.Arg2)
+	case "^":
+		time.Sleep(cfg.Exponentiation)
+		if task.Arg1 == 0 && task.Arg2 < 0 {
+			return 0, &pb.TaskError{
+				Code:    errDivisionByZero,
+				Message: fmt.Sprintf("%g ^ %g", task.Arg1, task.Arg2),
+			}
+		}
+		res = math.Pow(task.Arg1, task.Arg2)
+	default:
+		if _, ok := expr.Funcs[task.Operation]; ok {
+			return call(cfg, task)
+		}
+		return 0, &pb.TaskError{
+			Code:    errUnsupportedOperation,
+			Message: fmt.Sprintf("unsupported operation %q", task.Operation),
+		}
+	}
+
+	if taskErr := checkFinite(res, fmt.Sprintf("%g %s %g", task.Arg1, task.Operation, task.Arg2)); taskErr != nil {
+		return 0, taskErr
+	}
+	return res, nil
+}
+
+// call - вычисляет встроенную функцию над task.Args
+func call(cfg *config.Config, task *pb.TaskResponse) (float64, *pb.TaskError) {
+	time.Sleep(cfg.FunctionTime(task.Operation))
+	if err := expr.CheckArity(task.Operation, len(task.Args)); err != nil {
+		return 0, &pb.TaskError{Code: errUnsupportedOperation, Message: err.Error()}
+	}
+
+	res, err := expr.Funcs[task.Operation].Call(task.Args)
+	if err != nil {
+		return 0, &pb.TaskError{
+			Code:    errDomain,
+			Message: fmt.Sprintf("%s: %s", expr.FormatCall(task.Operation, task.Args), err),
+		}
+	}
+	if taskErr := checkFinite(res, expr.FormatCall(task.Operation, task.Args)); taskErr != nil {
+		return 0, taskErr
+	}
+	return res, nil
+}
+
+// checkFinite - ошибка задачи, если результат операции op не конечное число:
+// NaN - вне области определения, бесконечность - переполнение
+func checkFinite(res float64, op string) *pb.TaskError {
+	switch {
+	case math.IsNaN(res):
+		return &pb.TaskError{Code: errDomain, Message: op + " is not a real number"}
+	case math.IsInf(res, 0):
+		return &pb.TaskError{Code: errOverflow, Message: op + " is out of range"}
+	}
+	return nil
+}
+
+// mod - остаток от деления с округлением вниз: знак остатка совпадает со
+// знаком делителя, так что x = (x // y) * y + x % y
+func mod(x, y float64) float64 {
+	r := math.Mod(x, y)
+	if r != 0 && (r < 0) != (y < 0) {
+		r += y
+	}
+	return r
+}
@@ -0,0 +1,114 @@
+package agent
+
+import (
+	"math"
+	"testing"
+
+	"github.com/kms-qwe/DAEC/internal/config"
+	pb "github.com/kms-qwe/DAEC/internal/protos/gen/go/daec"
+)
+
+func TestExecuteNegativeOperands(t *testing.T) {
+	tests := []struct {
+		op   string
+		x, y float64
+		want float64
+	}{
+		{op: "//", x: 7, y: 2, want: 3},
+		{op: "//", x: -7, y: 2, want: -4},
+		{op: "//", x: 7, y: -2, want: -4},
+		{op: "//", x: -7, y: -2, want: 3},
+		{op: "%", x: 7, y: 3, want: 1},
+		{op: "%", x: -7, y: 3, want: 2},
+		{op: "%", x: 7, y: -3, want: -2},
+		{op: "%", x: -7, y: -3, want: -1},
+		{op: "%", x: -6, y: 3, want: 0},
+		{op: "^", x: -2, y: 3, want: -8},
+		{op: "^", x: 2, y: -1, want: 0.5},
+	}
+	for _, tt := range tests {
+		got, terr := execute(&config.Config{}, &pb.TaskResponse{Operation: tt.op, Arg1: tt.x, Arg2: tt.y})
+		if terr != nil {
+			t.Errorf("%g %s %g: %v", tt.x, tt.op, tt.y, terr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%g %s %g = %g, want %g", tt.x, tt.op, tt.y, got, tt.want)
+		}
+	}
+}
+
+func TestExecuteErrors(t *testing.T) {
+	tests := []struct {
+		op   string
+		x, y float64
+		code string
+	}{
+		{op: "//", x: -1, y: 0, code: errDivisionByZero},
+		{op: "%", x: -1, y: 0, code: errDivisionByZero},
+		{op: "^", x: 0, y: -1, code: errDivisionByZero},
+		{op: "^", x: -8, y: 0.5, code: errDomain},
+		{op: "^", x: 10, y: 400, code: errOverflow},
+	}
+	for _, tt := range tests {
+		_, terr := execute(&config.Config{}, &pb.TaskResponse{Operation: tt.op, Arg1: tt.x, Arg2: tt.y})
+		if terr == nil || terr.Code != tt.code {
+			t.Errorf("%g %s %g: error %v, want code %s", tt.x, tt.op, tt.y, terr, tt.code)
+		}
+	}
+}
+
+func TestExecuteFunctions(t *testing.T) {
+	tests := []struct {
+		op   string
+		args []float64
+		want float64
+	}{
+		{op: "sqrt", args: []float64{16}, want: 4},
+		{op: "abs", args: []float64{-2.5}, want: 2.5},
+		{op: "min", args: []float64{3, -1, 2}, want: -1},
+		{op: "max", args: []float64{3}, want: 3},
+		{op: "round", args: []float64{-2.5}, want: -3},
+		{op: "floor", args: []float64{-0.5}, want: -1},
+	}
+	for _, tt := range tests {
+		got, terr := execute(&config.Config{}, &pb.TaskResponse{Operation: tt.op, Args: tt.args})
+		if terr != nil {
+			t.Errorf("%s%v: %v", tt.op, tt.args, terr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s%v = %g, want %g", tt.op, tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestExecuteFunctionErrors(t *testing.T) {
+	tests := []struct {
+		op   string
+		args []float64
+		code string
+		msg  string
+	}{
+		{op: "sqrt", args: []float64{-4}, code: errDomain, msg: "sqrt(-4): argument must be non-negative"},
+		{op: "log", args: []float64{0}, code: errDomain, msg: "log(0): argument must be positive"},
+		{op: "sin", args: []float64{math.Inf(1)}, code: errDomain, msg: "sin(+Inf) is not a real number"},
+		{op: "exp", args: []float64{1000}, code: errOverflow, msg: "exp(1000) is out of range"},
+		{op: "sqrt", args: []float64{1, 2}, code: errUnsupportedOperation, msg: "sqrt expects 1 argument, got 2"},
+		{op: "cbrt", args: []float64{8}, code: errUnsupportedOperation, msg: `unsupported operation "cbrt"`},
+	}
+	for _, tt := range tests {
+		_, terr := execute(&config.Config{}, &pb.TaskResponse{Operation: tt.op, Args: tt.args})
+		if terr == nil || terr.Code != tt.code || terr.Message != tt.msg {
+			t.Errorf("%s%v: error %v, want %s %q", tt.op, tt.args, terr, tt.code, tt.msg)
+		}
+	}
+}
+
+func TestExecuteNotANumber(t *testing.T) {
+	inf := math.Inf(1)
+	_, terr := execute(&config.Config{}, &pb.TaskResponse{Operation: "-", Arg1: inf, Arg2: inf})
+	if terr == nil || terr.Code != errDomain || terr.Message != "+Inf - +Inf is not a real number" {
+		t.Errorf("inf - inf: error %v, want %s", terr, errDomain)
+	}
+}
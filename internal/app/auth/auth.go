@@ -2,7 +2,6 @@ package auth
 
 import (
 	"context"
-	"database/sql"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -13,17 +12,25 @@ import (
 	"strings"
 	"time"
 
-	"github.com/golang-jwt/jwt/v5"
+	"github.com/kms-qwe/DAEC/internal/domain/models"
+	"github.com/kms-qwe/DAEC/internal/lib/jwt"
 	"github.com/kms-qwe/DAEC/internal/lib/logger/sl"
+	"github.com/kms-qwe/DAEC/internal/lib/password"
+	"github.com/kms-qwe/DAEC/internal/storage"
 )
 
-const hmacSampleSecret = "super_secret_signature"
-
 type Server struct {
 	tokenTTL   time.Duration
-	log        *slog.Logger
-	Port       string
-	router     *http.ServeMux
+	refreshTTL time.Duration
+	// watchInterval - период опроса бд при ожидании выражений
+	watchInterval time.Duration
+	tokens        *jwt.KeySet
+	log           *slog.Logger
+	Port          string
+	router        *http.ServeMux
+	httpServer    *http.Server
+	// shutdown закрывается при остановке сервера, ожидающие запросы завершаются
+	shutdown   chan struct{}
 	UsrStorage UsrStorage
 }
 
@@ -31,9 +38,29 @@ type UsrStorage interface {
 	SaveNewUsr(context.Context, User) (int64, error)
 	IsUsrLoggin(context.Context, User) (bool, error)
 	GetPassword(context.Context, string) (string, int64, error)
-	GetAll(context.Context, int64) ([]Expr, error)
+	UpdatePassword(context.Context, int64, string) error
+	GetAll(context.Context, int64, ExprFilter) ([]Expr, error)
 	GetById(context.Context, int64, int64) (Expr, error)
-	SaveNewExpr(context.Context, int64, string, string) (int64, error)
+	GetExprStates(context.Context, int64, int64) ([]ExprState, error)
+	GetEventsCursor(context.Context, int64) (int64, error)
+	CancelExpr(context.Context, int64, int64) error
+	DeleteExpr(context.Context, int64, int64) error
+	SaveNewExpr(context.Context, int64, NewExpr) (int64, error)
+	SaveNewExprs(context.Context, int64, []NewExpr) ([]int64, error)
+	SaveNewExprOnce(context.Context, int64, string, string, time.Duration, NewExpr) (int64, bool, error)
+	GetByIds(context.Context, []int64, int64) ([]Expr, error)
+	GetSettings(context.Context, int64) (Settings, error)
+	SaveSettings(context.Context, int64, Settings) error
+	GetWebhooks(context.Context, int64) ([]WebhookDelivery, error)
+	SaveSweep(context.Context, int64, Sweep, []NewExpr) (int64, error)
+	GetSweep(context.Context, int64, int64) (Sweep, error)
+	GetSweepExprs(context.Context, int64) ([]Expr, error)
+	SaveRefreshToken(context.Context, models.RefreshToken) error
+	GetRefreshToken(context.Context, string) (models.RefreshToken, error)
+	UseRefreshToken(context.Context, string) (bool, error)
+	RevokeRefreshFamily(context.Context, string) error
+	RevokeAccessToken(context.Context, string, time.Time) error
+	IsAccessTokenRevoked(context.Context, string) (bool, error)
 }
 type User struct {
 	Login    string
@@ -44,9 +71,19 @@ type Expr struct {
 	Exp    string
 	Status string
 	Result float64
+	// Reason - причина ошибки для выражений со статусом error
+	Reason string `json:",omitempty"`
+	// CreatedAt - время создания, нет у выражений, созданных до его появления
+	CreatedAt *time.Time `json:",omitempty"`
+	// Bindings - значения переменных, с которыми вычислялось выражение
+	Bindings map[string]float64 `json:",omitempty"`
 }
 type calculateRequest struct {
 	Expression string `json:"expression"`
+	// CallbackURL - адрес вебхука о завершении, по умолчанию из настроек пользователя
+	CallbackURL string `json:"callback_url"`
+	// Bindings - значения переменных выражения
+	Bindings map[string]float64 `json:"bindings,omitempty"`
 }
 type ResponseToNewExpr struct {
 	ID int64 `json:"id"`
@@ -54,32 +91,62 @@ type ResponseToNewExpr struct {
 
 type ResponseToGiveAllExpr struct {
 	Exprs []Expr `json:"expressions"`
+	// NextCursor - курсор следующей страницы, пусто на последней странице
+	NextCursor string `json:"next_cursor,omitempty"`
 }
 
 // NewServer - конструктор для создания нового сервера
-func NewServer(log *slog.Logger, port string, tokenTTL time.Duration, UsrStorage UsrStorage) *Server {
-	return &Server{
-		log:        log,
-		Port:       port,
-		router:     http.NewServeMux(),
-		tokenTTL:   tokenTTL,
-		UsrStorage: UsrStorage,
+func NewServer(log *slog.Logger, port string, tokenTTL, refreshTTL, watchInterval time.Duration, tokens *jwt.KeySet, UsrStorage UsrStorage) *Server {
+	s := &Server{
+		log:           log,
+		Port:          port,
+		router:        http.NewServeMux(),
+		tokenTTL:      tokenTTL,
+		refreshTTL:    refreshTTL,
+		watchInterval: watchInterval,
+		tokens:        tokens,
+		shutdown:      make(chan struct{}),
+		UsrStorage:    UsrStorage,
+	}
+	s.httpServer = &http.Server{
+		Addr:              port,
+		Handler:           withRequestID(s.router),
+		ReadHeaderTimeout: 10 * time.Second,
 	}
+	// Shutdown не прерывает запросы, поэтому ожидание и поток событий завершаются сами
+	s.httpServer.RegisterOnShutdown(func() { close(s.shutdown) })
+	return s
 }
 
 // SetupRoutes - метод для настройки маршрутов
 func (s *Server) SetupRoutes() {
 	s.router.HandleFunc("/", s.handleRoot())
 	s.router.HandleFunc("/api/v1/calculate", s.NewExprRoot())
+	s.router.HandleFunc("/api/v1/calculate/batch", s.BatchExprRoot())
 	s.router.HandleFunc("/api/v1/expressions", s.AllExprRoot())
 	s.router.HandleFunc("/api/v1/expression", s.ExprByIdRoot())
+	s.router.HandleFunc("/api/v1/expression/cancel", s.CancelExprRoot())
+	s.router.HandleFunc("/api/v1/expressions/events", s.EventsRoot())
+	s.router.HandleFunc("/api/v1/expressions/batch", s.BatchStatusRoot())
+	s.router.HandleFunc("/api/v1/sweeps", s.SweepsRoot())
+	s.router.HandleFunc("/api/v1/sweep", s.SweepRoot())
+	s.router.HandleFunc("/api/v1/sweep/results", s.SweepResultsRoot())
+	s.router.HandleFunc("/api/v1/webhooks", s.WebhooksRoot())
+	s.router.HandleFunc("/api/v1/settings", s.SettingsRoot())
 	s.router.HandleFunc("/api/v1/register", s.NewUsrRoot())
 	s.router.HandleFunc("/api/v1/login", s.GiveTokenRoot())
+	s.router.HandleFunc("/api/v1/refresh", s.RefreshRoot())
+	s.router.HandleFunc("/api/v1/logout", s.LogoutRoot())
+	s.router.HandleFunc("/.well-known/jwks.json", s.JWKSRoot())
 }
 
 // handleRoot - обработчик для корневого маршрута
 func (s *Server) handleRoot() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			writeError(w, r, http.StatusNotFound, codeNotFound, "Маршрут не найден")
+			return
+		}
 		fmt.Fprintf(w, "Welcome to the root route!")
 	}
 }
@@ -87,16 +154,16 @@ func (s *Server) handleRoot() http.HandlerFunc {
 func (s *Server) NewUsrRoot() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		const op = "auth.NewUsrRoot"
-		log := s.log.With(slog.String("op", op))
+		log := s.log.With(slog.String("op", op), slog.String("request_id", requestID(r)))
 		if r.Method != http.MethodPost {
-			http.Error(w, "Метод не поддерживается", http.StatusInternalServerError)
+			methodNotAllowed(w, r, http.MethodPost)
 			log.Info("Ошибка регистрации: Метод не поддерживается")
 			return
 		}
 
 		body, err := io.ReadAll(r.Body)
 		if err != nil {
-			http.Error(w, "Ошибка при чтении тела запроса", http.StatusInternalServerError)
+			writeError(w, r, http.StatusBadRequest, codeBadRequest, "Ошибка при чтении тела запроса")
 			log.Info("Ошибка регистрации: Ошибка при чтении тела запроса", sl.Err(err))
 			return
 		}
@@ -106,30 +173,43 @@ func (s *Server) NewUsrRoot() http.HandlerFunc {
 
 		err = json.Unmarshal(body, &User)
 		if err != nil {
-			http.Error(w, "Ошибка при декодировании JSON", http.StatusInternalServerError)
+			writeError(w, r, http.StatusBadRequest, codeBadRequest, "Ошибка при декодировании JSON")
 			log.Info("Ошибка регистрации: Ошибка при декодировании JSON", sl.Err(err))
 			return
 		}
 
 		if User.Login == "" || User.Password == "" {
-			http.Error(w, "Ошибка при декодировании JSON", http.StatusInternalServerError)
+			writeError(w, r, http.StatusUnprocessableEntity, codeValidation, "Пустой логин или пароль")
 			log.Info("Ошибка регистрации: пустой логин или пароль")
 			return
 		}
+		if len(User.Password) > password.MaxLen {
+			writeError(w, r, http.StatusUnprocessableEntity, codeValidation, fmt.Sprintf("Пароль длиннее %d байт", password.MaxLen))
+			log.Info("Ошибка регистрации: слишком длинный пароль")
+			return
+		}
 
-		isLoggin, err := s.UsrStorage.IsUsrLoggin(context.TODO(), User)
+		isLoggin, err := s.UsrStorage.IsUsrLoggin(r.Context(), User)
 		if err != nil {
-			http.Error(w, "Ошибка при обращении к бд", http.StatusInternalServerError)
+			writeError(w, r, http.StatusInternalServerError, codeInternal, "Ошибка при обращении к бд")
 			log.Info("Ошибка регистрации: ошибка при обращении к бд", sl.Err(err))
 			return
 		}
 		if isLoggin {
-			http.Error(w, "Пользователь существует", http.StatusInternalServerError)
+			writeError(w, r, http.StatusConflict, codeUserExists, "Пользователь существует")
 			log.Info("Ошибка регистрации: пользователь существует")
 			return
 		}
-		if _, err := s.UsrStorage.SaveNewUsr(context.TODO(), User); err != nil {
-			http.Error(w, "Ошибка при обращении к бд", http.StatusInternalServerError)
+		hash, err := password.Hash(User.Password)
+		if err != nil {
+			writeError(w, r, http.StatusInternalServerError, codeInternal, "Не удалось сохранить пароль")
+			log.Info("Ошибка регистрации: не удалось захэшировать пароль", sl.Err(err))
+			return
+		}
+		User.Password = hash
+
+		if _, err := s.UsrStorage.SaveNewUsr(r.Context(), User); err != nil {
+			writeError(w, r, http.StatusInternalServerError, codeInternal, "Ошибка при обращении к бд")
 			log.Info("Ошибка регистрации: ошибка при сохранении в  бд", sl.Err(err))
 			return
 		}
@@ -140,17 +220,17 @@ func (s *Server) NewUsrRoot() http.HandlerFunc {
 }
 func (s *Server) GiveTokenRoot() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		const op = "auth.NewUsrRoot"
-		log := s.log.With(slog.String("op", op))
+		const op = "auth.GiveTokenRoot"
+		log := s.log.With(slog.String("op", op), slog.String("request_id", requestID(r)))
 		if r.Method != http.MethodGet {
-			http.Error(w, "Метод не поддерживается", http.StatusInternalServerError)
-			log.Info("Вывод списка выражений не произведен: Метод не поддерживается")
+			methodNotAllowed(w, r, http.MethodGet)
+			log.Info("Ошибка входа: Метод не поддерживается")
 			return
 		}
 		body, err := io.ReadAll(r.Body)
 		if err != nil {
-			http.Error(w, "Ошибка при чтении тела запроса", http.StatusInternalServerError)
-			log.Info("Ошибка регистрации: Ошибка при чтении тела запроса", sl.Err(err))
+			writeError(w, r, http.StatusBadRequest, codeBadRequest, "Ошибка при чтении тела запроса")
+			log.Info("Ошибка входа: Ошибка при чтении тела запроса", sl.Err(err))
 			return
 		}
 		defer r.Body.Close()
@@ -159,77 +239,97 @@ func (s *Server) GiveTokenRoot() http.HandlerFunc {
 
 		err = json.Unmarshal(body, &User)
 		if err != nil {
-			http.Error(w, "Ошибка при декодировании JSON", http.StatusInternalServerError)
-			log.Info("Ошибка регистрации: Ошибка при декодировании JSON", sl.Err(err))
+			writeError(w, r, http.StatusBadRequest, codeBadRequest, "Ошибка при декодировании JSON")
+			log.Info("Ошибка входа: Ошибка при декодировании JSON", sl.Err(err))
 			return
 		}
 
 		if User.Login == "" || User.Password == "" {
-			http.Error(w, "Ошибка при декодировании JSON", http.StatusInternalServerError)
-			log.Info("Ошибка регистрации: пустой логин или пароль")
+			writeError(w, r, http.StatusUnprocessableEntity, codeValidation, "Пустой логин или пароль")
+			log.Info("Ошибка входа: пустой логин или пароль")
 			return
 		}
 
-		pass, id, err := s.UsrStorage.GetPassword(context.TODO(), User.Login)
+		pass, id, err := s.UsrStorage.GetPassword(r.Context(), User.Login)
+		if errors.Is(err, storage.ErrUserNotFound) {
+			// сверяем с фиктивным хэшем, чтобы время ответа не выдавало существующие логины
+			password.VerifyDummy(User.Password)
+			writeError(w, r, http.StatusUnauthorized, codeUnauthorized, "Неверный логин или пароль")
+			log.Info("Ошибка входа: пользователь не найден")
+			return
+		}
 		if err != nil {
-			http.Error(w, "Ошибка при обращении к бд", http.StatusInternalServerError)
-			log.Info("Ошибка регистрации: ошибка при обращении к бд", sl.Err(err))
+			writeError(w, r, http.StatusInternalServerError, codeInternal, "Ошибка при обращении к бд")
+			log.Info("Ошибка входа: ошибка при обращении к бд", sl.Err(err))
 			return
 		}
-		if User.Password != pass {
-			http.Error(w, "Ошибка при обращении к бд", http.StatusInternalServerError)
-			log.Info("Ошибка регистрации: неверный пароль", slog.Any("User", User), slog.String("acPass", pass))
+		ok, needsUpgrade, err := password.Verify(pass, User.Password)
+		if err != nil {
+			writeError(w, r, http.StatusInternalServerError, codeInternal, "Ошибка при проверке пароля")
+			log.Info("Ошибка входа: ошибка при проверке пароля", slog.Int64("user_id", id), sl.Err(err))
 			return
 		}
-		now := time.Now()
-		token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-			"login": User.Login,
-			"id":    id,
-			"nbf":   now.Unix(),
-			"exp":   now.Add(s.tokenTTL).Unix(),
-			"iat":   now.Unix(),
-		})
-
-		tokenString, err := token.SignedString([]byte(hmacSampleSecret))
+		if !ok {
+			writeError(w, r, http.StatusUnauthorized, codeUnauthorized, "Неверный логин или пароль")
+			log.Info("Ошибка входа: неверный пароль", slog.Int64("user_id", id))
+			return
+		}
+		if needsUpgrade {
+			// пароль сохранен до перехода на хэши - заменяем его хэшем
+			if err := s.upgradePassword(r.Context(), id, User.Password); err != nil {
+				log.Info("пароль не перехэширован", slog.Int64("user_id", id), sl.Err(err))
+			} else {
+				log.Info("пароль перехэширован", slog.Int64("user_id", id))
+			}
+		}
+		resp, err := s.issueTokens(r.Context(), models.User{ID: id, Login: User.Login}, "")
 		if err != nil {
-			http.Error(w, "Could not generate token", http.StatusInternalServerError)
-			log.Info("Could not generate token")
+			writeError(w, r, http.StatusInternalServerError, codeInternal, "Не удалось выпустить токен")
+			log.Info("Could not generate token", sl.Err(err))
 			return
 		}
 
-		// Возвращаем JWT токен в теле ответа
+		// Возвращаем JWT токен и refresh-токен в теле ответа
 
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]string{"token": tokenString})
+		json.NewEncoder(w).Encode(resp)
+	}
+}
+
+// JWKSRoot - открытые ключи для проверки наших токенов другими сервисами
+func (s *Server) JWKSRoot() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, r, http.MethodGet)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		json.NewEncoder(w).Encode(s.tokens.JWKS())
 	}
 }
 
 func (s *Server) NewExprRoot() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		const op = "auth.NewExprRoot"
-		log := s.log.With(slog.String("op", op))
+		log := s.log.With(slog.String("op", op), slog.String("request_id", requestID(r)))
 
 		if r.Method != http.MethodPost {
-			http.Error(w, "Метод не поддерживается", http.StatusInternalServerError)
+			methodNotAllowed(w, r, http.MethodPost)
 			log.Info("Не принято на вычисление: Метод не поддерживается")
 			return
 		}
 
-		isValid, userID, err := s.validateJWTToken(r)
-		if err != nil || !isValid {
-			if err != nil {
-				http.Error(w, "Ошибка валидации токена", http.StatusInternalServerError)
-				log.Info("Не принято на вычисление: ошибка при валидации токена", sl.Err(err))
-				return
-			}
-			http.Error(w, "Ошибка валидации токена", http.StatusInternalServerError)
-			log.Info("Не принято на вычисление: токен не валиден", sl.Err(err))
+		userID, err := s.validateJWTToken(r)
+		if err != nil {
+			writeTokenError(w, r, err)
+			log.Info("Не принято на вычисление: ошибка при валидации токена", sl.Err(err))
 			return
 		}
 
 		body, err := io.ReadAll(r.Body)
 		if err != nil {
-			http.Error(w, "Ошибка при чтении тела запроса", http.StatusInternalServerError)
+			writeError(w, r, http.StatusBadRequest, codeBadRequest, "Ошибка при чтении тела запроса")
 			log.Info("Не принято на вычисление: Ошибка при чтении тела запроса", sl.Err(err))
 			return
 		}
@@ -239,21 +339,53 @@ func (s *Server) NewExprRoot() http.HandlerFunc {
 
 		err = json.Unmarshal(body, &data)
 		if err != nil {
-			http.Error(w, "Ошибка при декодировании JSON", http.StatusInternalServerError)
+			writeError(w, r, http.StatusBadRequest, codeBadRequest, "Ошибка при декодировании JSON")
 			log.Info("Не принято на вычисление: Ошибка при декодировании JSON", sl.Err(err))
 			return
 		}
 
-		polishExpr, err := infixToPostfix(data.Expression)
-		if err != nil {
-			http.Error(w, "Невалидные данные", http.StatusUnprocessableEntity)
-			log.Info("Не принято на вычисление: Невалидные данные", sl.Err(err), slog.Any("data", data))
+		key := r.Header.Get(idempotencyKeyHeader)
+		if len(key) > maxIdempotencyKeyLen {
+			writeError(w, r, http.StatusBadRequest, codeBadRequest, fmt.Sprintf("Idempotency-Key длиннее %d символов", maxIdempotencyKeyLen))
+			log.Info("Не принято на вычисление: слишком длинный Idempotency-Key")
+			return
+		}
+
+		newExpr, itemErr := checkExpr(data)
+		if itemErr != nil {
+			writeError(w, r, http.StatusUnprocessableEntity, itemErr.Code, itemErr.Message)
+			log.Info("Не принято на вычисление: Невалидные данные", slog.String("reason", itemErr.Message), slog.Any("data", data))
 			return
 		}
 
-		id, err := s.UsrStorage.SaveNewExpr(context.TODO(), userID, data.Expression, polishExpr)
+		if newExpr.CallbackURL == "" {
+			settings, err := s.UsrStorage.GetSettings(r.Context(), userID)
+			if err != nil {
+				writeError(w, r, http.StatusInternalServerError, codeInternal, "Ошибка при обращении к бд")
+				log.Info("Не принято на вычисление: ошибка при обращении к бд", sl.Err(err))
+				return
+			}
+			newExpr.CallbackURL = settings.CallbackURL
+		}
+
+		var id int64
+		if key == "" {
+			id, err = s.UsrStorage.SaveNewExpr(r.Context(), userID, newExpr)
+		} else {
+			var replayed bool
+			id, replayed, err = s.UsrStorage.SaveNewExprOnce(r.Context(), userID, key, requestFingerprint(data), idempotencyTTL, newExpr)
+			if replayed {
+				w.Header().Set(idempotentReplayedHeader, "true")
+				log.Info("Повтор запроса с тем же Idempotency-Key", slog.Int64("id", id))
+			}
+		}
+		if errors.Is(err, storage.ErrIdempotencyKeyReused) {
+			writeError(w, r, http.StatusConflict, codeIdempotencyReused, "Idempotency-Key уже использован с другим запросом")
+			log.Info("Не принято на вычисление: Idempotency-Key использован с другим запросом")
+			return
+		}
 		if err != nil {
-			http.Error(w, "Ошибка при обращении к бд", http.StatusInternalServerError)
+			writeError(w, r, http.StatusInternalServerError, codeInternal, "Ошибка при обращении к бд")
 			log.Info("Не принято на вычисление: ошибка при обращении к бд", sl.Err(err))
 			return
 		}
@@ -262,7 +394,6 @@ func (s *Server) NewExprRoot() http.HandlerFunc {
 
 		w.Header().Set("Content-Type", "application/json")
 		if err := json.NewEncoder(w).Encode(response); err != nil {
-			http.Error(w, "Failed to encode response", http.StatusInternalServerError)
 			log.Info("Не принято на вычисление: ошибка при записи id", sl.Err(err))
 		}
 		log.Info("Принято на вычисление")
@@ -273,40 +404,51 @@ func (s *Server) NewExprRoot() http.HandlerFunc {
 func (s *Server) AllExprRoot() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		const op = "auth.AllExprRoot"
-		log := s.log.With(slog.String("op", op))
+		log := s.log.With(slog.String("op", op), slog.String("request_id", requestID(r)))
 		if r.Method != http.MethodGet {
-			http.Error(w, "Метод не поддерживается", http.StatusInternalServerError)
+			methodNotAllowed(w, r, http.MethodGet)
 			log.Info("Выражения не отданы: Метод не поддерживается")
 			return
 		}
 
-		isValid, userID, err := s.validateJWTToken(r)
-		if err != nil || !isValid {
-			if err != nil {
-				http.Error(w, "Ошибка валидации токена", http.StatusInternalServerError)
-				log.Info("Выражения не отданы: ошибка при валидации токена", sl.Err(err))
-				return
-			}
-			http.Error(w, "Ошибка валидации токена", http.StatusInternalServerError)
-			log.Info("Выражения не отданы: токен не валиден", sl.Err(err))
+		userID, err := s.validateJWTToken(r)
+		if err != nil {
+			writeTokenError(w, r, err)
+			log.Info("Выражения не отданы: ошибка при валидации токена", sl.Err(err))
 			return
 		}
 
-		exprs, err := s.UsrStorage.GetAll(context.TODO(), userID)
+		filter, err := parseExprFilter(r.URL.Query())
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, codeBadRequest, "Некорректные параметры: "+err.Error())
+			log.Info("Выражения не отданы: некорректные параметры", sl.Err(err))
+			return
+		}
+
+		// лишняя строка показывает, есть ли следующая страница
+		limit := filter.Limit
+		filter.Limit++
+		exprs, err := s.UsrStorage.GetAll(r.Context(), userID, filter)
 
 		if err != nil {
-			http.Error(w, "Ошибка при обращении к бд", http.StatusInternalServerError)
+			writeError(w, r, http.StatusInternalServerError, codeInternal, "Ошибка при обращении к бд")
 			log.Info("Выражения не отданы: ошибка при обращении к бд", sl.Err(err))
 			return
 		}
 
 		ans := ResponseToGiveAllExpr{Exprs: exprs}
+		if len(exprs) > limit {
+			ans.Exprs = exprs[:limit]
+			ans.NextCursor = encodeCursor(exprs[limit-1].Id)
+		}
+		if ans.Exprs == nil {
+			ans.Exprs = []Expr{}
+		}
 		w.Header().Set("Content-Type", "application/json")
 		if err := json.NewEncoder(w).Encode(ans); err != nil {
-			http.Error(w, "Failed to encode response", http.StatusInternalServerError)
 			log.Info("Выражения не отданы: ошибка при записи id", sl.Err(err))
 		}
-		log.Info("Выражения отданы", slog.Any("exprs", exprs))
+		log.Info("Выражения отданы", slog.Int("count", len(ans.Exprs)), slog.Bool("has_more", ans.NextCursor != ""))
 
 	}
 }
@@ -314,50 +456,72 @@ func (s *Server) AllExprRoot() http.HandlerFunc {
 func (s *Server) ExprByIdRoot() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		const op = "auth.ExprByIdRoot"
-		log := s.log.With(slog.String("op", op))
+		log := s.log.With(slog.String("op", op), slog.String("request_id", requestID(r)))
+		if r.Method == http.MethodDelete {
+			s.deleteExpr(w, r)
+			return
+		}
 		if r.Method != http.MethodGet {
-			http.Error(w, "Метод не поддерживается", http.StatusInternalServerError)
+			methodNotAllowed(w, r, http.MethodGet, http.MethodDelete)
 			log.Info("Выражения не отданы: Метод не поддерживается")
 			return
 		}
 
-		isValid, userID, err := s.validateJWTToken(r)
-		if err != nil || !isValid {
-			if err != nil {
-				http.Error(w, "Ошибка валидации токена", http.StatusInternalServerError)
-				log.Info("Выражения не отданы: ошибка при валидации токена", sl.Err(err))
-				return
-			}
-			http.Error(w, "Ошибка валидации токена", http.StatusInternalServerError)
-			log.Info("Выражения не отданы: токен не валиден", sl.Err(err))
+		userID, err := s.validateJWTToken(r)
+		if err != nil {
+			writeTokenError(w, r, err)
+			log.Info("Выражения не отданы: ошибка при валидации токена", sl.Err(err))
 			return
 		}
 
 		queryParams := r.URL.Query()
 		id, err := strconv.Atoi(queryParams.Get("id"))
 		if err != nil {
-			http.Error(w, "ошибка получения id", http.StatusInternalServerError)
+			writeError(w, r, http.StatusBadRequest, codeBadRequest, "Некорректный id")
 			log.Info("Выражения не отданы: ошибка при получении id", sl.Err(err))
 			return
 		}
+		wait, err := parseWait(queryParams.Get("wait"))
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, codeBadRequest, "Некорректный wait: "+err.Error())
+			log.Info("Выражения не отданы: ошибка при получении wait", sl.Err(err))
+			return
+		}
 
-		expr, err := s.UsrStorage.GetById(context.TODO(), int64(id), userID)
+		expr, err := s.UsrStorage.GetById(r.Context(), int64(id), userID)
 
-		if errors.Is(err, sql.ErrNoRows) {
-			http.Error(w, "нет записей", http.StatusInternalServerError)
-			log.Info("Выражения не отданы: нет записей", sl.Err(err))
+		if errors.Is(err, storage.ErrExprNotFound) {
+			writeError(w, r, http.StatusNotFound, codeNotFound, "Выражение не найдено")
+			log.Info("Выражения не отданы: нет записей", slog.Int("id", id))
 			return
 		}
 		if err != nil {
-			http.Error(w, "Ошибка при обращении к бд", http.StatusInternalServerError)
+			writeError(w, r, http.StatusInternalServerError, codeInternal, "Ошибка при обращении к бд")
 			log.Info("Выражения не отданы: ошибка при обращении к бд", sl.Err(err))
 			return
 		}
 
+		if wait > 0 {
+			expr, err = s.waitExpr(r.Context(), expr, userID, wait)
+			if r.Context().Err() != nil {
+				log.Info("Выражения не отданы: клиент не дождался", slog.Int("id", id))
+				return
+			}
+			if errors.Is(err, storage.ErrExprNotFound) {
+				writeError(w, r, http.StatusNotFound, codeNotFound, "Выражение не найдено")
+				log.Info("Выражения не отданы: выражение удалено во время ожидания", slog.Int("id", id))
+				return
+			}
+			if err != nil {
+				writeError(w, r, http.StatusInternalServerError, codeInternal, "Ошибка при обращении к бд")
+				log.Info("Выражения не отданы: ошибка при обращении к бд", sl.Err(err))
+				return
+			}
+		}
+
 		ans := ResponseToGiveAllExpr{Exprs: []Expr{expr}}
 		w.Header().Set("Content-Type", "application/json")
 		if err := json.NewEncoder(w).Encode(ans); err != nil {
-			http.Error(w, "Failed to encode response", http.StatusInternalServerError)
 			log.Info("Выражения не отданы: ошибка при записи id", sl.Err(err))
 		}
 		log.Info("Выражения отданы", slog.Any("expr", expr))
@@ -369,35 +533,60 @@ func (s *Server) ExprByIdRoot() http.HandlerFunc {
 func (s *Server) MustRun() {
 	s.SetupRoutes()
 	s.log.Info("Starting server on port", slog.String("port", s.Port))
-	if err := http.ListenAndServe(s.Port, s.router); err != nil {
+	if err := s.httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 		s.log.Error("http server is not setuped", sl.Err(err))
 		panic("http server is not setuped")
 	}
 }
 
-func (s *Server) validateJWTToken(r *http.Request) (bool, int64, error) {
-	const op = "auth.validateJWTToken"
+// Stop - перестает принимать соединения и ждет завершения текущих запросов, но не дольше timeout
+func (s *Server) Stop(timeout time.Duration) {
+	const op = "auth.Stop"
 	log := s.log.With(slog.String("op", op))
-	tokenString := getTokenFromHeader(r)
-	log.Info("got token", slog.String("token", tokenString))
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(hmacSampleSecret), nil
-	})
 
+	log.Info("stopping http server", slog.String("port", s.Port))
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := s.httpServer.Shutdown(ctx); err != nil {
+		log.Info("http server is not stopped gracefully", sl.Err(err))
+		s.httpServer.Close()
+	}
+}
+
+// validateJWTToken - проверяет токен доступа из заголовка Authorization и
+// возвращает id пользователя. Ошибки неверного или отозванного токена
+// оборачивают jwt.ErrInvalid
+func (s *Server) validateJWTToken(r *http.Request) (int64, error) {
+	const op = "auth.validateJWTToken"
+	log := s.log.With(slog.String("op", op), slog.String("request_id", requestID(r)))
+	tokenString := getTokenFromHeader(r)
+	claims, err := s.tokens.Parse(tokenString)
 	if err != nil {
 		log.Info("не удалось проверить токен", sl.Err(err))
-		return false, 0, err
+		return 0, err
 	}
 
-	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		return true, int64(claims["id"].(float64)), nil
+	revoked, err := s.UsrStorage.IsAccessTokenRevoked(r.Context(), claims.ID)
+	if err != nil {
+		log.Info("не удалось проверить отзыв токена", sl.Err(err))
+		return 0, err
+	}
+	if revoked {
+		log.Info("токен отозван", slog.Int64("user_id", claims.UID))
+		return 0, fmt.Errorf("%w: token revoked", jwt.ErrInvalid)
 	}
 
-	return false, 0, nil
+	return claims.UID, nil
 }
+
+func (s *Server) upgradePassword(ctx context.Context, userID int64, plain string) error {
+	hash, err := password.Hash(plain)
+	if err != nil {
+		return err
+	}
+	return s.UsrStorage.UpdatePassword(ctx, userID, hash)
+}
+
 func getTokenFromHeader(r *http.Request) string {
 	authHeader := r.Header.Get("Authorization")
 	if authHeader == "" {
@@ -0,0 +1,279 @@
+package auth_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kms-qwe/DAEC/internal/app/auth"
+	"github.com/kms-qwe/DAEC/internal/lib/jwt"
+	"github.com/kms-qwe/DAEC/internal/lib/password"
+	"github.com/kms-qwe/DAEC/internal/storage/sqlite"
+)
+
+// newTestServer - сервер авторизации поверх чистой бд во временном каталоге
+func newTestServer(t *testing.T) (*auth.Server, *sqlite.AuthStorage) {
+	t.Helper()
+	s, storage, _ := newTestServerAt(t)
+	return s, storage
+}
+
+// newTestServerAt - то же, что newTestServer, и путь к бд, чтобы тест мог
+// менять ее так же, как оркестратор
+func newTestServerAt(t *testing.T) (*auth.Server, *sqlite.AuthStorage, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "daec.db")
+	initStorage, err := sqlite.NewInitStorage(path)
+	if err != nil {
+		t.Fatalf("NewInitStorage: %v", err)
+	}
+	if err := initStorage.Init(context.Background()); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	storage, err := sqlite.NewAuthStorage(path)
+	if err != nil {
+		t.Fatalf("NewAuthStorage: %v", err)
+	}
+	key, err := jwt.LoadKey("test", "HS256", "test-secret", "", "")
+	if err != nil {
+		t.Fatalf("LoadKey: %v", err)
+	}
+	tokens, err := jwt.NewKeySet("test", key)
+	if err != nil {
+		t.Fatalf("NewKeySet: %v", err)
+	}
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	return auth.NewServer(log, "0", time.Hour, time.Hour, 10*time.Millisecond, tokens, storage), storage, path
+}
+
+func serve(h http.HandlerFunc, method, body string) *httptest.ResponseRecorder {
+	return serveAuth(h, method, "", body)
+}
+
+// serveAuth - запрос с токеном доступа в заголовке Authorization
+func serveAuth(h http.HandlerFunc, method, token, body string) *httptest.ResponseRecorder {
+	return serveURL(h, method, "/", token, body)
+}
+
+func serveURL(h http.HandlerFunc, method, target, token, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	h(rec, req)
+	return rec
+}
+
+// login - регистрирует пользователя и возвращает выданную при входе пару токенов
+func login(t *testing.T, s *auth.Server, name string) auth.ResponseToLogin {
+	t.Helper()
+	body := `{"login":"` + name + `","password":"secret"}`
+	if rec := serve(s.NewUsrRoot(), http.MethodPost, body); rec.Code != http.StatusOK {
+		t.Fatalf("register = %d %q", rec.Code, rec.Body.String())
+	}
+	return decodeTokens(t, serve(s.GiveTokenRoot(), http.MethodGet, body))
+}
+
+func decodeTokens(t *testing.T, rec *httptest.ResponseRecorder) auth.ResponseToLogin {
+	t.Helper()
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d %q, want 200", rec.Code, rec.Body.String())
+	}
+	var resp auth.ResponseToLogin
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode %q: %v", rec.Body.String(), err)
+	}
+	if resp.Token == "" || resp.RefreshToken == "" {
+		t.Fatalf("tokens missing in %q", rec.Body.String())
+	}
+	return resp
+}
+
+// wantError - ответ с ошибкой в едином формате с заданными статусом и кодом
+func wantError(t *testing.T, rec *httptest.ResponseRecorder, status int, code string) {
+	t.Helper()
+	if rec.Code != status {
+		t.Errorf("status = %d, want %d (body %q)", rec.Code, status, rec.Body.String())
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json; charset=utf-8" {
+		t.Errorf("Content-Type = %q, want application/json; charset=utf-8", ct)
+	}
+	var resp auth.ErrorResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode %q: %v", rec.Body.String(), err)
+	}
+	if resp.Error.Code != code {
+		t.Errorf("error.code = %q, want %q", resp.Error.Code, code)
+	}
+	if resp.Error.Message == "" {
+		t.Errorf("error.message is empty")
+	}
+}
+
+func refresh(s *auth.Server, token string) *httptest.ResponseRecorder {
+	return serve(s.RefreshRoot(), http.MethodPost, `{"refresh_token":"`+token+`"}`)
+}
+
+func TestLoginUpgradesPlaintext(t *testing.T) {
+	s, storage := newTestServer(t)
+	ctx := context.Background()
+
+	// строка пользователя, сохраненная до перехода на хэши
+	if _, err := storage.SaveNewUsr(ctx, auth.User{Login: "old", Password: "secret"}); err != nil {
+		t.Fatalf("SaveNewUsr: %v", err)
+	}
+
+	rec := serve(s.GiveTokenRoot(), http.MethodGet, `{"login":"old","password":"secret"}`)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"token"`) {
+		t.Fatalf("login = %d %q, want 200 with token", rec.Code, rec.Body.String())
+	}
+
+	stored, _, err := storage.GetPassword(ctx, "old")
+	if err != nil {
+		t.Fatalf("GetPassword: %v", err)
+	}
+	if !password.IsHash(stored) {
+		t.Fatalf("stored password %q is not a bcrypt hash", stored)
+	}
+	if ok, needsUpgrade, err := password.Verify(stored, "secret"); !ok || needsUpgrade || err != nil {
+		t.Fatalf("Verify(upgraded) = %v, %v, %v", ok, needsUpgrade, err)
+	}
+
+	// повторный вход идет уже по хэшу
+	rec = serve(s.GiveTokenRoot(), http.MethodGet, `{"login":"old","password":"secret"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("second login = %d %q, want 200", rec.Code, rec.Body.String())
+	}
+}
+
+func TestLoginWrongPasswordKeepsPlaintext(t *testing.T) {
+	s, storage := newTestServer(t)
+	ctx := context.Background()
+	if _, err := storage.SaveNewUsr(ctx, auth.User{Login: "old", Password: "secret"}); err != nil {
+		t.Fatalf("SaveNewUsr: %v", err)
+	}
+
+	wantError(t, serve(s.GiveTokenRoot(), http.MethodGet, `{"login":"old","password":"wrong"}`), http.StatusUnauthorized, "unauthorized")
+	stored, _, err := storage.GetPassword(ctx, "old")
+	if err != nil {
+		t.Fatalf("GetPassword: %v", err)
+	}
+	if stored != "secret" {
+		t.Fatalf("stored password = %q, want untouched plaintext", stored)
+	}
+}
+
+func TestRegisterHashesPassword(t *testing.T) {
+	s, storage := newTestServer(t)
+
+	rec := serve(s.NewUsrRoot(), http.MethodPost, `{"login":"new","password":"secret"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("register = %d %q, want 200", rec.Code, rec.Body.String())
+	}
+	stored, _, err := storage.GetPassword(context.Background(), "new")
+	if err != nil {
+		t.Fatalf("GetPassword: %v", err)
+	}
+	if !password.IsHash(stored) {
+		t.Fatalf("stored password %q is not a bcrypt hash", stored)
+	}
+}
+
+func TestRegisterRejectsLongPassword(t *testing.T) {
+	s, _ := newTestServer(t)
+
+	long := strings.Repeat("a", password.MaxLen+1)
+	wantError(t, serve(s.NewUsrRoot(), http.MethodPost, `{"login":"new","password":"`+long+`"}`), http.StatusUnprocessableEntity, "validation_failed")
+}
+
+func TestRefreshRotates(t *testing.T) {
+	s, _ := newTestServer(t)
+	first := login(t, s, "user")
+
+	second := decodeTokens(t, refresh(s, first.RefreshToken))
+	if second.RefreshToken == first.RefreshToken {
+		t.Fatalf("refresh token was not rotated")
+	}
+	third := decodeTokens(t, refresh(s, second.RefreshToken))
+	if third.RefreshToken == second.RefreshToken {
+		t.Fatalf("refresh token was not rotated")
+	}
+}
+
+func TestRefreshReuseRevokesFamily(t *testing.T) {
+	s, _ := newTestServer(t)
+	first := login(t, s, "user")
+	second := decodeTokens(t, refresh(s, first.RefreshToken))
+
+	// повторное предъявление уже обмененного токена - признак кражи
+	wantError(t, refresh(s, first.RefreshToken), http.StatusUnauthorized, "invalid_token")
+	// отозвано все семейство, в том числе токен, выданный при ротации
+	wantError(t, refresh(s, second.RefreshToken), http.StatusUnauthorized, "invalid_token")
+
+	// другое семейство того же пользователя не затронуто
+	other := decodeTokens(t, serve(s.GiveTokenRoot(), http.MethodGet, `{"login":"user","password":"secret"}`))
+	decodeTokens(t, refresh(s, other.RefreshToken))
+}
+
+func TestRefreshUnknownToken(t *testing.T) {
+	s, _ := newTestServer(t)
+	wantError(t, refresh(s, "unknown"), http.StatusUnauthorized, "invalid_token")
+}
+
+func TestLogoutRevokesTokens(t *testing.T) {
+	s, _ := newTestServer(t)
+	tokens := login(t, s, "user")
+
+	rec := serveAuth(s.LogoutRoot(), http.MethodPost, tokens.Token, `{"refresh_token":"`+tokens.RefreshToken+`"}`)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("logout = %d %q, want 204", rec.Code, rec.Body.String())
+	}
+
+	wantError(t, refresh(s, tokens.RefreshToken), http.StatusUnauthorized, "invalid_token")
+	wantError(t, serveAuth(s.AllExprRoot(), http.MethodGet, tokens.Token, ""), http.StatusUnauthorized, "invalid_token")
+}
+
+func TestErrorStatusCodes(t *testing.T) {
+	s, _ := newTestServer(t)
+	user := login(t, s, "user")
+	other := login(t, s, "other")
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		method  string
+		target  string
+		token   string
+		body    string
+		status  int
+		code    string
+	}{
+		{"register wrong method", s.NewUsrRoot(), http.MethodGet, "/", "", "", http.StatusMethodNotAllowed, "method_not_allowed"},
+		{"register bad json", s.NewUsrRoot(), http.MethodPost, "/", "", "{", http.StatusBadRequest, "bad_request"},
+		{"register empty password", s.NewUsrRoot(), http.MethodPost, "/", "", `{"login":"x"}`, http.StatusUnprocessableEntity, "validation_failed"},
+		{"register existing login", s.NewUsrRoot(), http.MethodPost, "/", "", `{"login":"user","password":"secret"}`, http.StatusConflict, "user_exists"},
+		{"login unknown user", s.GiveTokenRoot(), http.MethodGet, "/", "", `{"login":"nobody","password":"secret"}`, http.StatusUnauthorized, "unauthorized"},
+		{"login wrong password", s.GiveTokenRoot(), http.MethodGet, "/", "", `{"login":"user","password":"wrong"}`, http.StatusUnauthorized, "unauthorized"},
+		{"login empty login", s.GiveTokenRoot(), http.MethodGet, "/", "", `{"password":"secret"}`, http.StatusUnprocessableEntity, "validation_failed"},
+		{"calculate without token", s.NewExprRoot(), http.MethodPost, "/", "", `{"expression":"1+1"}`, http.StatusUnauthorized, "invalid_token"},
+		{"calculate bad token", s.NewExprRoot(), http.MethodPost, "/", "garbage", `{"expression":"1+1"}`, http.StatusUnauthorized, "invalid_token"},
+		{"calculate invalid expression", s.NewExprRoot(), http.MethodPost, "/", user.Token, `{"expression":"1 +"}`, http.StatusUnprocessableEntity, "invalid_expression"},
+		{"expression bad id", s.ExprByIdRoot(), http.MethodGet, "/?id=abc", user.Token, "", http.StatusBadRequest, "bad_request"},
+		{"expression not found", s.ExprByIdRoot(), http.MethodGet, "/?id=999", user.Token, "", http.StatusNotFound, "not_found"},
+		{"refresh empty token", s.RefreshRoot(), http.MethodPost, "/", "", `{}`, http.StatusUnprocessableEntity, "validation_failed"},
+		{"logout foreign refresh token", s.LogoutRoot(), http.MethodPost, "/", user.Token, `{"refresh_token":"` + other.RefreshToken + `"}`, http.StatusForbidden, "forbidden"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wantError(t, serveURL(tt.handler, tt.method, tt.target, tt.token, tt.body), tt.status, tt.code)
+		})
+	}
+}
@@ -0,0 +1,274 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/kms-qwe/DAEC/internal/expr"
+	"github.com/kms-qwe/DAEC/internal/lib/logger/sl"
+)
+
+const (
+	// maxBatchSize - сколько выражений принимает и отдает один пакетный запрос
+	maxBatchSize = 1000
+	// maxBatchBody - предельный размер тела пакетного запроса
+	maxBatchBody = 4 << 20
+)
+
+// NewExpr - проверенное выражение, готовое к сохранению. Expr - исходная
+// запись с переменными, PolishExpr - запись с подставленными Bindings
+type NewExpr struct {
+	Expr        string
+	PolishExpr  string
+	CallbackURL string
+	Bindings    map[string]float64
+	// Reason - непустая, если выражение не определено при этих Bindings;
+	// такое выражение сохраняется сразу со статусом error
+	Reason string
+}
+
+type batchRequest struct {
+	Expressions []calculateRequest `json:"expressions"`
+}
+
+type ResponseToBatch struct {
+	IDs []int64 `json:"ids"`
+}
+
+type ResponseToBatchStatus struct {
+	Exprs    []Expr  `json:"expressions"`
+	NotFound []int64 `json:"not_found"`
+}
+
+// checkExpr - разбирает выражение запроса, подставляет значения переменных
+// и проверяет адрес вебхука
+func checkExpr(req calculateRequest) (NewExpr, *ItemError) {
+	tree, err := expr.Parse(req.Expression)
+	if err != nil {
+		return NewExpr{}, &ItemError{Code: codeInvalidExpression, Message: "Невалидное выражение: " + err.Error()}
+	}
+	if errs := expr.Unbound(tree, req.Bindings); len(errs) > 0 {
+		msgs := make([]string, len(errs))
+		for i, e := range errs {
+			msgs[i] = e.Error()
+		}
+		return NewExpr{}, &ItemError{Code: codeUnboundVariable, Message: "Не заданы значения переменных: " + strings.Join(msgs, "; ")}
+	}
+	names := make([]string, 0, len(req.Bindings))
+	for name := range req.Bindings {
+		names = append(names, name)
+	}
+	if ierr := unknownVars(tree, names); ierr != nil {
+		return NewExpr{}, ierr
+	}
+	tree, err = expr.Bind(tree, req.Bindings)
+	if err != nil {
+		return NewExpr{}, &ItemError{Code: codeInvalidExpression, Message: "Невалидное выражение: " + err.Error()}
+	}
+	polishExpr, err := expr.Postfix(tree)
+	if err != nil {
+		return NewExpr{}, &ItemError{Code: codeInvalidExpression, Message: "Невалидное выражение: " + err.Error()}
+	}
+	if req.CallbackURL != "" {
+		if err := validateCallbackURL(req.CallbackURL); err != nil {
+			return NewExpr{}, &ItemError{Code: codeValidation, Message: "Некорректный callback_url: " + err.Error()}
+		}
+	}
+	return NewExpr{
+		Expr:        req.Expression,
+		PolishExpr:  polishExpr,
+		CallbackURL: req.CallbackURL,
+		Bindings:    req.Bindings,
+	}, nil
+}
+
+// unknownVars - ошибка со всеми именами из names, которых нет в выражении,
+// по алфавиту; nil, если все переменные есть
+func unknownVars(tree expr.Node, names []string) *ItemError {
+	vars := expr.Vars(tree)
+	var unknown []string
+	for _, name := range names {
+		if !slices.Contains(vars, name) {
+			unknown = append(unknown, name)
+		}
+	}
+	switch len(unknown) {
+	case 0:
+		return nil
+	case 1:
+		return &ItemError{Code: codeValidation, Message: fmt.Sprintf("Переменной %q нет в выражении", unknown[0])}
+	}
+	slices.Sort(unknown)
+	quoted := make([]string, len(unknown))
+	for i, name := range unknown {
+		quoted[i] = strconv.Quote(name)
+	}
+	return &ItemError{Code: codeValidation, Message: "Переменных " + strings.Join(quoted, ", ") + " нет в выражении"}
+}
+
+// BatchExprRoot - принимает пакет выражений. Выражения сохраняются одной
+// транзакцией: если хотя бы одно невалидно, не сохраняется ни одно, а в
+// ответе перечислены ошибки всех невалидных элементов
+func (s *Server) BatchExprRoot() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "auth.BatchExprRoot"
+		log := s.log.With(slog.String("op", op), slog.String("request_id", requestID(r)))
+		if r.Method != http.MethodPost {
+			methodNotAllowed(w, r, http.MethodPost)
+			log.Info("Пакет не принят: Метод не поддерживается")
+			return
+		}
+
+		userID, err := s.validateJWTToken(r)
+		if err != nil {
+			writeTokenError(w, r, err)
+			log.Info("Пакет не принят: ошибка при валидации токена", sl.Err(err))
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBatchBody))
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, codeBadRequest, "Ошибка при чтении тела запроса")
+			log.Info("Пакет не принят: Ошибка при чтении тела запроса", sl.Err(err))
+			return
+		}
+		defer r.Body.Close()
+
+		var data batchRequest
+		if err := json.Unmarshal(body, &data); err != nil {
+			writeError(w, r, http.StatusBadRequest, codeBadRequest, "Ошибка при декодировании JSON")
+			log.Info("Пакет не принят: Ошибка при декодировании JSON", sl.Err(err))
+			return
+		}
+		if len(data.Expressions) == 0 || len(data.Expressions) > maxBatchSize {
+			writeError(w, r, http.StatusUnprocessableEntity, codeValidation, fmt.Sprintf("В пакете должно быть от 1 до %d выражений", maxBatchSize))
+			log.Info("Пакет не принят: недопустимый размер", slog.Int("size", len(data.Expressions)))
+			return
+		}
+
+		settings, err := s.UsrStorage.GetSettings(r.Context(), userID)
+		if err != nil {
+			writeError(w, r, http.StatusInternalServerError, codeInternal, "Ошибка при обращении к бд")
+			log.Info("Пакет не принят: ошибка при обращении к бд", sl.Err(err))
+			return
+		}
+
+		exprs := make([]NewExpr, 0, len(data.Expressions))
+		var itemErrs []ItemError
+		for i, req := range data.Expressions {
+			e, itemErr := checkExpr(req)
+			if itemErr != nil {
+				itemErr.Index = i
+				itemErrs = append(itemErrs, *itemErr)
+				continue
+			}
+			if e.CallbackURL == "" {
+				e.CallbackURL = settings.CallbackURL
+			}
+			exprs = append(exprs, e)
+		}
+		if len(itemErrs) > 0 {
+			writeItemErrors(w, r, "Пакет содержит невалидные выражения", itemErrs)
+			log.Info("Пакет не принят: невалидные выражения", slog.Int("size", len(data.Expressions)), slog.Int("invalid", len(itemErrs)))
+			return
+		}
+
+		ids, err := s.UsrStorage.SaveNewExprs(r.Context(), userID, exprs)
+		if err != nil {
+			writeError(w, r, http.StatusInternalServerError, codeInternal, "Ошибка при обращении к бд")
+			log.Info("Пакет не принят: ошибка при обращении к бд", sl.Err(err))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(ResponseToBatch{IDs: ids}); err != nil {
+			log.Info("Пакет принят, но id не отданы: ошибка при записи", sl.Err(err))
+		}
+		log.Info("Пакет принят на вычисление", slog.Int("size", len(ids)))
+	}
+}
+
+// BatchStatusRoot - выражения пользователя по списку id (?ids=1,2,3);
+// id, которых нет у пользователя, перечислены в not_found
+func (s *Server) BatchStatusRoot() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "auth.BatchStatusRoot"
+		log := s.log.With(slog.String("op", op), slog.String("request_id", requestID(r)))
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, r, http.MethodGet)
+			log.Info("Выражения не отданы: Метод не поддерживается")
+			return
+		}
+
+		userID, err := s.validateJWTToken(r)
+		if err != nil {
+			writeTokenError(w, r, err)
+			log.Info("Выражения не отданы: ошибка при валидации токена", sl.Err(err))
+			return
+		}
+
+		ids, err := parseIDs(r.URL.Query().Get("ids"))
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, codeBadRequest, "Некорректные ids: "+err.Error())
+			log.Info("Выражения не отданы: ошибка при получении ids", sl.Err(err))
+			return
+		}
+
+		exprs, err := s.UsrStorage.GetByIds(r.Context(), ids, userID)
+		if err != nil {
+			writeError(w, r, http.StatusInternalServerError, codeInternal, "Ошибка при обращении к бд")
+			log.Info("Выражения не отданы: ошибка при обращении к бд", sl.Err(err))
+			return
+		}
+
+		found := make(map[int64]bool, len(exprs))
+		for _, e := range exprs {
+			found[e.Id] = true
+		}
+		ans := ResponseToBatchStatus{Exprs: exprs, NotFound: []int64{}}
+		if ans.Exprs == nil {
+			ans.Exprs = []Expr{}
+		}
+		for _, id := range ids {
+			if !found[id] {
+				ans.NotFound = append(ans.NotFound, id)
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(ans); err != nil {
+			log.Info("Выражения не отданы: ошибка при записи", sl.Err(err))
+		}
+		log.Info("Выражения отданы", slog.Int("count", len(exprs)), slog.Int("not_found", len(ans.NotFound)))
+	}
+}
+
+// parseIDs - список id через запятую без повторов, не больше maxBatchSize
+func parseIDs(v string) ([]int64, error) {
+	if v == "" {
+		return nil, fmt.Errorf("ids is empty")
+	}
+	parts := strings.Split(v, ",")
+	if len(parts) > maxBatchSize {
+		return nil, fmt.Errorf("more than %d ids", maxBatchSize)
+	}
+	ids := make([]int64, 0, len(parts))
+	seen := make(map[int64]bool, len(parts))
+	for _, p := range parts {
+		id, err := strconv.ParseInt(strings.TrimSpace(p), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid id %q", p)
+		}
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
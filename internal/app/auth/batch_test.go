@@ -0,0 +1,78 @@
+package auth_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"testing"
+
+	"github.com/kms-qwe/DAEC/internal/app/auth"
+)
+
+func TestBatchRejectsWhole(t *testing.T) {
+	f := newWatchFixture(t)
+
+	body := `{"expressions":[
+		{"expression":"1 + 1"},
+		{"expression":"1 +"},
+		{"expression":"2 * 2","callback_url":"ftp://example.com"},
+		{"expression":"3 - 1"}
+	]}`
+	rec := serveAuth(f.s.BatchExprRoot(), http.MethodPost, f.token, body)
+	wantError(t, rec, http.StatusUnprocessableEntity, "validation_failed")
+
+	var resp auth.ErrorResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode %q: %v", rec.Body.String(), err)
+	}
+	var got []string
+	for _, item := range resp.Error.Items {
+		got = append(got, fmt.Sprintf("%d:%s", item.Index, item.Code))
+	}
+	if want := []string{"1:invalid_expression", "2:validation_failed"}; !slices.Equal(got, want) {
+		t.Errorf("items = %v, want %v", got, want)
+	}
+
+	// валидные элементы отклоненного пакета тоже не сохранены
+	if exprs := f.list(t, url.Values{}).Exprs; len(exprs) != 0 {
+		t.Errorf("saved %d expressions of a rejected batch", len(exprs))
+	}
+}
+
+func TestBatchSaveAndStatus(t *testing.T) {
+	f := newWatchFixture(t)
+
+	rec := serveAuth(f.s.BatchExprRoot(), http.MethodPost, f.token, `{"expressions":[{"expression":"1 + 1"},{"expression":"2 * 3"}]}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("batch = %d %q, want 200", rec.Code, rec.Body.String())
+	}
+	var batch auth.ResponseToBatch
+	if err := json.Unmarshal(rec.Body.Bytes(), &batch); err != nil || len(batch.IDs) != 2 {
+		t.Fatalf("decode %q: %v", rec.Body.String(), err)
+	}
+
+	target := fmt.Sprintf("/?ids=%d,999,%d", batch.IDs[0], batch.IDs[1])
+	rec = serveURL(f.s.BatchStatusRoot(), http.MethodGet, target, f.token, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d %q, want 200", rec.Code, rec.Body.String())
+	}
+	var status auth.ResponseToBatchStatus
+	if err := json.Unmarshal(rec.Body.Bytes(), &status); err != nil {
+		t.Fatalf("decode %q: %v", rec.Body.String(), err)
+	}
+	if got := exprIDs(status.Exprs); !slices.Equal(got, batch.IDs) {
+		t.Errorf("expressions = %v, want %v", got, batch.IDs)
+	}
+	if !slices.Equal(status.NotFound, []int64{999}) {
+		t.Errorf("not_found = %v, want [999]", status.NotFound)
+	}
+}
+
+func TestBatchBadRequests(t *testing.T) {
+	f := newWatchFixture(t)
+	wantError(t, serveAuth(f.s.BatchExprRoot(), http.MethodPost, f.token, `{"expressions":[]}`), http.StatusUnprocessableEntity, "validation_failed")
+	wantError(t, serveAuth(f.s.BatchExprRoot(), http.MethodPost, f.token, `{`), http.StatusBadRequest, "bad_request")
+	wantError(t, serveURL(f.s.BatchStatusRoot(), http.MethodGet, "/?ids=1,x", f.token, ""), http.StatusBadRequest, "bad_request")
+}
@@ -0,0 +1,64 @@
+package auth_test
+
+import (
+	"encoding/json"
+	"maps"
+	"net/http"
+	"testing"
+
+	"github.com/kms-qwe/DAEC/internal/app/auth"
+)
+
+func TestBindingsSaved(t *testing.T) {
+	f := newWatchFixture(t)
+	id := decodeID(t, f.submit(t, "k1", `{"expression":"sqrt(x - 5) * y","bindings":{"x":1,"y":2}}`))
+
+	e := decodeExpr(t, f.getExpr(id, "0s"))
+	if e.Exp != "sqrt(x - 5) * y" {
+		t.Errorf("expression = %q, want the source with variables", e.Exp)
+	}
+	if want := map[string]float64{"x": 1, "y": 2}; !maps.Equal(e.Bindings, want) {
+		t.Errorf("bindings = %v, want %v", e.Bindings, want)
+	}
+}
+
+func TestBindingsErrors(t *testing.T) {
+	f := newWatchFixture(t)
+	tests := []struct {
+		body string
+		code string
+		msg  string
+	}{
+		{
+			body: `{"expression":"a + b * c","bindings":{"b":1}}`,
+			code: "unbound_variable",
+			msg:  `Не заданы значения переменных: pos 0: unbound variable "a"; pos 8: unbound variable "c"`,
+		},
+		{
+			body: `{"expression":"x","bindings":{"x":1,"y":2}}`,
+			code: "validation_failed",
+			msg:  `Переменной "y" нет в выражении`,
+		},
+		{
+			body: `{"expression":"x","bindings":{"x":1,"zeta":2,"b":3,"a":4}}`,
+			code: "validation_failed",
+			msg:  `Переменных "a", "b", "zeta" нет в выражении`,
+		},
+		{
+			body: `{"expression":"1 / x","bindings":{"x":0}}`,
+			code: "invalid_expression",
+			msg:  "Невалидное выражение: pos 4: division by zero",
+		},
+	}
+	for _, tt := range tests {
+		rec := f.submit(t, tt.body, tt.body)
+		wantError(t, rec, http.StatusUnprocessableEntity, tt.code)
+		var resp auth.ErrorResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decode %q: %v", rec.Body.String(), err)
+		}
+		if resp.Error.Message != tt.msg {
+			t.Errorf("%s: message = %q, want %q", tt.body, resp.Error.Message, tt.msg)
+		}
+	}
+}
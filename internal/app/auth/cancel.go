@@ -0,0 +1,106 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/kms-qwe/DAEC/internal/lib/logger/sl"
+	"github.com/kms-qwe/DAEC/internal/storage"
+)
+
+// CancelExprRoot - отменяет вычисляемое выражение. Оркестратор перестает
+// выдавать его задачи и отбрасывает опоздавшие результаты
+func (s *Server) CancelExprRoot() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "auth.CancelExprRoot"
+		log := s.log.With(slog.String("op", op), slog.String("request_id", requestID(r)))
+		if r.Method != http.MethodPost {
+			methodNotAllowed(w, r, http.MethodPost)
+			log.Info("Выражение не отменено: Метод не поддерживается")
+			return
+		}
+
+		userID, err := s.validateJWTToken(r)
+		if err != nil {
+			writeTokenError(w, r, err)
+			log.Info("Выражение не отменено: ошибка при валидации токена", sl.Err(err))
+			return
+		}
+
+		id, err := strconv.Atoi(r.URL.Query().Get("id"))
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, codeBadRequest, "Некорректный id")
+			log.Info("Выражение не отменено: ошибка при получении id", sl.Err(err))
+			return
+		}
+
+		err = s.UsrStorage.CancelExpr(r.Context(), int64(id), userID)
+		if errors.Is(err, storage.ErrExprNotFound) {
+			writeError(w, r, http.StatusNotFound, codeNotFound, "Выражение не найдено")
+			log.Info("Выражение не отменено: нет записей", slog.Int("id", id))
+			return
+		}
+		if errors.Is(err, storage.ErrExprFinished) {
+			writeError(w, r, http.StatusConflict, codeExprFinished, "Выражение уже завершено")
+			log.Info("Выражение не отменено: уже завершено", slog.Int("id", id))
+			return
+		}
+		if err != nil {
+			writeError(w, r, http.StatusInternalServerError, codeInternal, "Ошибка при обращении к бд")
+			log.Info("Выражение не отменено: ошибка при обращении к бд", sl.Err(err))
+			return
+		}
+
+		expr, err := s.UsrStorage.GetById(r.Context(), int64(id), userID)
+		if err != nil {
+			writeError(w, r, http.StatusInternalServerError, codeInternal, "Ошибка при обращении к бд")
+			log.Info("Выражение отменено, но не отдано: ошибка при обращении к бд", sl.Err(err))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(ResponseToGiveAllExpr{Exprs: []Expr{expr}}); err != nil {
+			log.Info("Выражение отменено, но не отдано: ошибка при записи", sl.Err(err))
+		}
+		log.Info("Выражение отменено", slog.Int("id", id))
+	}
+}
+
+// deleteExpr - DELETE /api/v1/expression: удаляет выражение; вычисляемое
+// выражение оркестратор снимает с вычисления, как отмененное
+func (s *Server) deleteExpr(w http.ResponseWriter, r *http.Request) {
+	const op = "auth.deleteExpr"
+	log := s.log.With(slog.String("op", op), slog.String("request_id", requestID(r)))
+
+	userID, err := s.validateJWTToken(r)
+	if err != nil {
+		writeTokenError(w, r, err)
+		log.Info("Выражение не удалено: ошибка при валидации токена", sl.Err(err))
+		return
+	}
+
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, codeBadRequest, "Некорректный id")
+		log.Info("Выражение не удалено: ошибка при получении id", sl.Err(err))
+		return
+	}
+
+	err = s.UsrStorage.DeleteExpr(r.Context(), int64(id), userID)
+	if errors.Is(err, storage.ErrExprNotFound) {
+		writeError(w, r, http.StatusNotFound, codeNotFound, "Выражение не найдено")
+		log.Info("Выражение не удалено: нет записей", slog.Int("id", id))
+		return
+	}
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, codeInternal, "Ошибка при обращении к бд")
+		log.Info("Выражение не удалено: ошибка при обращении к бд", sl.Err(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	log.Info("Выражение удалено", slog.Int("id", id))
+}
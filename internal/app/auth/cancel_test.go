@@ -0,0 +1,45 @@
+package auth_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestCancelExpr(t *testing.T) {
+	f := newWatchFixture(t)
+	id := f.addExpr(t)
+	target := fmt.Sprintf("/?id=%d", id)
+
+	rec := serveURL(f.s.CancelExprRoot(), http.MethodPost, target, f.token, "")
+	if e := decodeExpr(t, rec); e.Status != "cancelled" {
+		t.Fatalf("status = %q, want cancelled", e.Status)
+	}
+	// повторная отмена ничего не меняет
+	if e := decodeExpr(t, serveURL(f.s.CancelExprRoot(), http.MethodPost, target, f.token, "")); e.Status != "cancelled" {
+		t.Errorf("second cancel status = %q, want cancelled", e.Status)
+	}
+
+	done := f.addExpr(t)
+	f.exec(t, `UPDATE expressions SET status = 'done' WHERE expr_id = ?`, done)
+	wantError(t, serveURL(f.s.CancelExprRoot(), http.MethodPost, fmt.Sprintf("/?id=%d", done), f.token, ""), http.StatusConflict, "expression_finished")
+	wantError(t, serveURL(f.s.CancelExprRoot(), http.MethodPost, "/?id=999", f.token, ""), http.StatusNotFound, "not_found")
+}
+
+func TestDeleteExpr(t *testing.T) {
+	f := newWatchFixture(t)
+	id := f.addExpr(t)
+	target := fmt.Sprintf("/?id=%d", id)
+
+	if rec := serveURL(f.s.ExprByIdRoot(), http.MethodDelete, target, f.token, ""); rec.Code != http.StatusNoContent {
+		t.Fatalf("delete = %d %q, want 204", rec.Code, rec.Body.String())
+	}
+	wantError(t, serveURL(f.s.ExprByIdRoot(), http.MethodGet, target, f.token, ""), http.StatusNotFound, "not_found")
+	wantError(t, serveURL(f.s.ExprByIdRoot(), http.MethodDelete, target, f.token, ""), http.StatusNotFound, "not_found")
+
+	// чужое выражение не удаляется
+	other := login(t, f.s, "other")
+	mine := f.addExpr(t)
+	wantError(t, serveURL(f.s.ExprByIdRoot(), http.MethodDelete, fmt.Sprintf("/?id=%d", mine), other.Token, ""), http.StatusNotFound, "not_found")
+	decodeExpr(t, serveURL(f.s.ExprByIdRoot(), http.MethodGet, fmt.Sprintf("/?id=%d", mine), f.token, ""))
+}
@@ -0,0 +1,126 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/kms-qwe/DAEC/internal/lib/jwt"
+)
+
+// Коды ошибок API - стабильные значения поля error.code, по ним клиенты
+// различают ошибки, не разбирая текст сообщения
+const (
+	codeMethodNotAllowed  = "method_not_allowed"
+	codeBadRequest        = "bad_request"
+	codeValidation        = "validation_failed"
+	codeInvalidExpression = "invalid_expression"
+	codeUnboundVariable   = "unbound_variable"
+	codeUnauthorized      = "unauthorized"
+	codeInvalidToken      = "invalid_token"
+	codeForbidden         = "forbidden"
+	codeNotFound          = "not_found"
+	codeUserExists        = "user_exists"
+	codeExprFinished      = "expression_finished"
+	codeIdempotencyReused = "idempotency_key_reused"
+	codeSweepInProgress   = "sweep_in_progress"
+	codeInternal          = "internal"
+)
+
+const requestIDHeader = "X-Request-ID"
+
+// ErrorResponse - тело ответа с ошибкой
+type ErrorResponse struct {
+	Error ErrorBody `json:"error"`
+}
+
+type ErrorBody struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id,omitempty"`
+	// Items - ошибки отдельных элементов пакетного запроса
+	Items []ItemError `json:"items,omitempty"`
+}
+
+// ItemError - ошибка элемента пакетного запроса, Index - его номер в запросе
+type ItemError struct {
+	Index   int    `json:"index"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// writeError - отвечает ошибкой в едином формате
+func writeError(w http.ResponseWriter, r *http.Request, status int, code, msg string) {
+	if status == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", `Bearer`)
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(ErrorResponse{Error: ErrorBody{
+		Code:      code,
+		Message:   msg,
+		RequestID: requestID(r),
+	}})
+}
+
+// writeItemErrors - 422 с ошибками элементов пакетного запроса
+func writeItemErrors(w http.ResponseWriter, r *http.Request, msg string, items []ItemError) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	json.NewEncoder(w).Encode(ErrorResponse{Error: ErrorBody{
+		Code:      codeValidation,
+		Message:   msg,
+		RequestID: requestID(r),
+		Items:     items,
+	}})
+}
+
+// writeTokenError - 401 для неверного, истекшего или отозванного токена,
+// 500 если токен не удалось проверить
+func writeTokenError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, jwt.ErrInvalid) {
+		writeError(w, r, http.StatusUnauthorized, codeInvalidToken, "Токен не действителен")
+		return
+	}
+	writeError(w, r, http.StatusInternalServerError, codeInternal, "Ошибка валидации токена")
+}
+
+// methodNotAllowed - 405 с заголовком Allow
+func methodNotAllowed(w http.ResponseWriter, r *http.Request, allowed ...string) {
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	writeError(w, r, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Метод не поддерживается")
+}
+
+type requestIDKey struct{}
+
+// withRequestID - присваивает запросу идентификатор (или берет его из заголовка
+// X-Request-ID) и возвращает его в ответе, чтобы ошибку можно было найти в логах
+func withRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if id == "" || len(id) > 64 {
+			id = newRequestID()
+		}
+		w.Header().Set(requestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
+}
+
+func requestID(r *http.Request) string {
+	id, _ := r.Context().Value(requestIDKey{}).(string)
+	return id
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,96 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWriteErrorEnvelope(t *testing.T) {
+	rec := httptest.NewRecorder()
+	writeError(rec, httptest.NewRequest(http.MethodGet, "/", nil), http.StatusConflict, codeUserExists, "Пользователь существует")
+
+	if rec.Code != http.StatusConflict {
+		t.Errorf("status = %d, want 409", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json; charset=utf-8" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	if h := rec.Header().Get("WWW-Authenticate"); h != "" {
+		t.Errorf("WWW-Authenticate = %q on a non-401 response", h)
+	}
+
+	// форма тела - часть API: клиенты разбирают именно эти поля
+	var body map[string]map[string]string
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode %q: %v", rec.Body.String(), err)
+	}
+	want := map[string]string{"code": "user_exists", "message": "Пользователь существует"}
+	if len(body) != 1 || len(body["error"]) != len(want) {
+		t.Fatalf("body = %q, want only error.code and error.message", rec.Body.String())
+	}
+	for k, v := range want {
+		if body["error"][k] != v {
+			t.Errorf("error.%s = %q, want %q", k, body["error"][k], v)
+		}
+	}
+}
+
+func TestWriteErrorUnauthorized(t *testing.T) {
+	rec := httptest.NewRecorder()
+	writeError(rec, httptest.NewRequest(http.MethodGet, "/", nil), http.StatusUnauthorized, codeUnauthorized, "Неверный логин или пароль")
+
+	if h := rec.Header().Get("WWW-Authenticate"); h != "Bearer" {
+		t.Errorf("WWW-Authenticate = %q, want Bearer", h)
+	}
+}
+
+func TestMethodNotAllowed(t *testing.T) {
+	rec := httptest.NewRecorder()
+	methodNotAllowed(rec, httptest.NewRequest(http.MethodPut, "/", nil), http.MethodGet, http.MethodPost)
+
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("status = %d, want 405", rec.Code)
+	}
+	if allow := rec.Header().Get("Allow"); allow != "GET, POST" {
+		t.Errorf("Allow = %q, want %q", allow, "GET, POST")
+	}
+}
+
+func TestRequestIDInEnvelope(t *testing.T) {
+	h := withRequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, r, http.StatusNotFound, codeNotFound, "Маршрут не найден")
+	}))
+
+	tests := []struct {
+		name   string
+		header string
+		keep   bool
+	}{
+		{"from header", "req-42", true},
+		{"generated", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				req.Header.Set(requestIDHeader, tt.header)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			var body ErrorResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decode %q: %v", rec.Body.String(), err)
+			}
+			id := rec.Header().Get(requestIDHeader)
+			if id == "" || body.Error.RequestID != id {
+				t.Errorf("header id = %q, body id = %q; want equal and non-empty", id, body.Error.RequestID)
+			}
+			if tt.keep && id != tt.header {
+				t.Errorf("id = %q, want %q from the request", id, tt.header)
+			}
+		})
+	}
+}
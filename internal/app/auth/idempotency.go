@@ -0,0 +1,26 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+)
+
+const (
+	idempotencyKeyHeader     = "Idempotency-Key"
+	idempotentReplayedHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLen     = 255
+	// idempotencyTTL - сколько помнится ключ идемпотентности; повтор с
+	// тем же ключом позже создаст новое выражение
+	idempotencyTTL = 24 * time.Hour
+)
+
+// requestFingerprint - отпечаток запроса на вычисление: тот же ключ
+// идемпотентности принимается повторно только с тем же отпечатком.
+// Считается по разобранному запросу, поэтому не зависит от пробелов и порядка полей
+func requestFingerprint(req calculateRequest) string {
+	b, _ := json.Marshal(req)
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,79 @@
+package auth_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kms-qwe/DAEC/internal/app/auth"
+)
+
+// submit - POST /api/v1/calculate с заголовком Idempotency-Key
+func (f *watchFixture) submit(t *testing.T, key, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+f.token)
+	req.Header.Set("Idempotency-Key", key)
+	rec := httptest.NewRecorder()
+	f.s.NewExprRoot()(rec, req)
+	return rec
+}
+
+func decodeID(t *testing.T, rec *httptest.ResponseRecorder) int64 {
+	t.Helper()
+	if rec.Code != http.StatusOK {
+		t.Fatalf("submit = %d %q, want 200", rec.Code, rec.Body.String())
+	}
+	var resp auth.ResponseToNewExpr
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode %q: %v", rec.Body.String(), err)
+	}
+	return resp.ID
+}
+
+func TestIdempotencyReplay(t *testing.T) {
+	f := newWatchFixture(t)
+	body := `{"expression":"1 + 1"}`
+
+	first := f.submit(t, "k1", body)
+	id := decodeID(t, first)
+	if first.Header().Get("Idempotent-Replayed") != "" {
+		t.Errorf("first request marked as replayed")
+	}
+
+	again := f.submit(t, "k1", body)
+	if got := decodeID(t, again); got != id {
+		t.Errorf("replayed id = %d, want %d", got, id)
+	}
+	if again.Header().Get("Idempotent-Replayed") != "true" {
+		t.Errorf("replay is not marked with Idempotent-Replayed")
+	}
+
+	wantError(t, f.submit(t, "k1", `{"expression":"2 + 2"}`), http.StatusConflict, "idempotency_key_reused")
+}
+
+func TestIdempotencyKeyAfterDelete(t *testing.T) {
+	f := newWatchFixture(t)
+	body := `{"expression":"1 + 1"}`
+	id := decodeID(t, f.submit(t, "k1", body))
+
+	rec := serveURL(f.s.ExprByIdRoot(), http.MethodDelete, fmt.Sprintf("/?id=%d", id), f.token, "")
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("delete = %d %q, want 204", rec.Code, rec.Body.String())
+	}
+
+	// ключ удален вместе с выражением: повтор создает выражение заново,
+	// а не возвращает id, которого больше нет
+	again := f.submit(t, "k1", body)
+	newID := decodeID(t, again)
+	if newID == id {
+		t.Fatalf("replay after delete returned the deleted id %d", id)
+	}
+	if again.Header().Get("Idempotent-Replayed") != "" {
+		t.Errorf("request after delete marked as replayed")
+	}
+	decodeExpr(t, serveURL(f.s.ExprByIdRoot(), http.MethodGet, fmt.Sprintf("/?id=%d", newID), f.token, ""))
+}
@@ -0,0 +1,106 @@
+package auth
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultPageSize = 100
+	maxPageSize     = 1000
+)
+
+// exprStatuses - допустимые значения фильтра status
+var exprStatuses = []string{exprComputing, "done", "error", "cancelled"}
+
+// ExprFilter - выборка списка выражений. Выражения упорядочены по id
+// (то есть по времени создания); AfterID - курсор, id последнего
+// выражения предыдущей страницы
+type ExprFilter struct {
+	Statuses      []string
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	Search        string
+	AfterID       int64
+	Desc          bool
+	Limit         int
+}
+
+// parseExprFilter - фильтр из параметров запроса limit, cursor, order, status,
+// created_after, created_before (RFC 3339) и q
+func parseExprFilter(q url.Values) (ExprFilter, error) {
+	f := ExprFilter{Limit: defaultPageSize, Search: q.Get("q")}
+
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxPageSize {
+			return ExprFilter{}, fmt.Errorf("limit must be from 1 to %d", maxPageSize)
+		}
+		f.Limit = n
+	}
+
+	if v := q.Get("cursor"); v != "" {
+		id, err := decodeCursor(v)
+		if err != nil {
+			return ExprFilter{}, fmt.Errorf("invalid cursor")
+		}
+		f.AfterID = id
+	}
+
+	switch q.Get("order") {
+	case "", "asc":
+	case "desc":
+		f.Desc = true
+	default:
+		return ExprFilter{}, fmt.Errorf("order must be asc or desc")
+	}
+
+	if v := q.Get("status"); v != "" {
+		for _, st := range strings.Split(v, ",") {
+			if !isExprStatus(st) {
+				return ExprFilter{}, fmt.Errorf("unknown status %q", st)
+			}
+			f.Statuses = append(f.Statuses, st)
+		}
+	}
+
+	for name, dst := range map[string]*time.Time{"created_after": &f.CreatedAfter, "created_before": &f.CreatedBefore} {
+		v := q.Get(name)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return ExprFilter{}, fmt.Errorf("%s must be RFC 3339 time", name)
+		}
+		*dst = t
+	}
+
+	return f, nil
+}
+
+func isExprStatus(st string) bool {
+	for _, s := range exprStatuses {
+		if s == st {
+			return true
+		}
+	}
+	return false
+}
+
+// encodeCursor - курсор страницы непрозрачен для клиента
+func encodeCursor(id int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(id, 10)))
+}
+
+func decodeCursor(cursor string) (int64, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(string(b), 10, 64)
+}
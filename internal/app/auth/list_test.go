@@ -0,0 +1,125 @@
+package auth_test
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"slices"
+	"testing"
+
+	"github.com/kms-qwe/DAEC/internal/app/auth"
+)
+
+// saveExpr - сохраняет выражение src пользователя
+func (f *watchFixture) saveExpr(t *testing.T, src string) int64 {
+	t.Helper()
+	id, err := f.st.SaveNewExpr(context.Background(), f.userID, auth.NewExpr{Expr: src})
+	if err != nil {
+		t.Fatalf("SaveNewExpr(%q): %v", src, err)
+	}
+	return id
+}
+
+// list - GET /api/v1/expressions с параметрами q
+func (f *watchFixture) list(t *testing.T, q url.Values) auth.ResponseToGiveAllExpr {
+	t.Helper()
+	rec := serveURL(f.s.AllExprRoot(), http.MethodGet, "/?"+q.Encode(), f.token, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("list %s = %d %q, want 200", q.Encode(), rec.Code, rec.Body.String())
+	}
+	var resp auth.ResponseToGiveAllExpr
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode %q: %v", rec.Body.String(), err)
+	}
+	return resp
+}
+
+func exprIDs(exprs []auth.Expr) []int64 {
+	var res []int64
+	for _, e := range exprs {
+		res = append(res, e.Id)
+	}
+	return res
+}
+
+func TestListCursorStable(t *testing.T) {
+	for _, order := range []string{"asc", "desc"} {
+		t.Run(order, func(t *testing.T) {
+			f := newWatchFixture(t)
+			var ids []int64
+			for i := 0; i < 5; i++ {
+				ids = append(ids, f.saveExpr(t, "1 + 1"))
+			}
+			if order == "desc" {
+				slices.Reverse(ids)
+			}
+
+			page := f.list(t, url.Values{"limit": {"2"}, "order": {order}})
+			got := exprIDs(page.Exprs)
+
+			// новые выражения между запросами страниц не сдвигают курсор:
+			// по возрастанию они окажутся в конце, по убыванию - не попадут
+			var added []int64
+			for page.NextCursor != "" {
+				added = append(added, f.saveExpr(t, "2 + 2"))
+				page = f.list(t, url.Values{"limit": {"2"}, "order": {order}, "cursor": {page.NextCursor}})
+				got = append(got, exprIDs(page.Exprs)...)
+			}
+
+			want := ids
+			if order == "asc" {
+				want = append(want, added...)
+			}
+			if !slices.Equal(got, want) {
+				t.Errorf("pages = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestListSearchEscapesLike(t *testing.T) {
+	f := newWatchFixture(t)
+	percent := f.saveExpr(t, "50 % 7")
+	underscore := f.saveExpr(t, "x_1 + 1")
+	f.saveExpr(t, "500 + 7")
+	plain := f.saveExpr(t, "x1 + 1")
+	backslash := f.saveExpr(t, `1 \ 2`)
+
+	tests := []struct {
+		q    string
+		want []int64
+	}{
+		{"%", []int64{percent}},
+		{"_", []int64{underscore}},
+		{"x_1", []int64{underscore}},
+		{`\`, []int64{backslash}},
+		{"+ 1", []int64{underscore, plain}},
+	}
+	for _, tt := range tests {
+		if got := exprIDs(f.list(t, url.Values{"q": {tt.q}}).Exprs); !slices.Equal(got, tt.want) {
+			t.Errorf("q=%q: ids = %v, want %v", tt.q, got, tt.want)
+		}
+	}
+}
+
+func TestListBadParams(t *testing.T) {
+	f := newWatchFixture(t)
+	f.saveExpr(t, "1 + 1")
+
+	tests := []url.Values{
+		{"cursor": {"not base64!"}},
+		{"cursor": {base64.RawURLEncoding.EncodeToString([]byte("abc"))}},
+		{"cursor": {"MQ=="}},
+		{"limit": {"0"}},
+		{"limit": {"1001"}},
+		{"order": {"up"}},
+		{"status": {"finished"}},
+		{"created_after": {"yesterday"}},
+	}
+	for _, q := range tests {
+		rec := serveURL(f.s.AllExprRoot(), http.MethodGet, "/?"+q.Encode(), f.token, "")
+		wantError(t, rec, http.StatusBadRequest, "bad_request")
+	}
+}
@@ -0,0 +1,225 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/kms-qwe/DAEC/internal/domain/models"
+	"github.com/kms-qwe/DAEC/internal/lib/logger/sl"
+	"github.com/kms-qwe/DAEC/internal/storage"
+)
+
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type ResponseToLogin struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// RefreshRoot - обменивает refresh-токен на новую пару токенов. Refresh-токен
+// одноразовый: повторное предъявление уже использованного токена считается
+// кражей, и все семейство токенов отзывается
+func (s *Server) RefreshRoot() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "auth.RefreshRoot"
+		log := s.log.With(slog.String("op", op), slog.String("request_id", requestID(r)))
+		if r.Method != http.MethodPost {
+			methodNotAllowed(w, r, http.MethodPost)
+			log.Info("Токен не обновлен: Метод не поддерживается")
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, codeBadRequest, "Ошибка при чтении тела запроса")
+			log.Info("Токен не обновлен: Ошибка при чтении тела запроса", sl.Err(err))
+			return
+		}
+		defer r.Body.Close()
+
+		var data refreshRequest
+		if err := json.Unmarshal(body, &data); err != nil {
+			writeError(w, r, http.StatusBadRequest, codeBadRequest, "Ошибка при декодировании JSON")
+			log.Info("Токен не обновлен: Ошибка при декодировании JSON", sl.Err(err))
+			return
+		}
+		if data.RefreshToken == "" {
+			writeError(w, r, http.StatusUnprocessableEntity, codeValidation, "Пустой refresh_token")
+			log.Info("Токен не обновлен: пустой refresh_token")
+			return
+		}
+
+		ctx := r.Context()
+		hash := hashRefreshToken(data.RefreshToken)
+		rt, err := s.UsrStorage.GetRefreshToken(ctx, hash)
+		if errors.Is(err, storage.ErrRefreshTokenNotFound) {
+			writeError(w, r, http.StatusUnauthorized, codeInvalidToken, "Токен не действителен")
+			log.Info("Токен не обновлен: неизвестный токен")
+			return
+		}
+		if err != nil {
+			writeError(w, r, http.StatusInternalServerError, codeInternal, "Ошибка при обращении к бд")
+			log.Info("Токен не обновлен: ошибка при обращении к бд", sl.Err(err))
+			return
+		}
+		log = log.With(slog.Int64("user_id", rt.UserID), slog.String("family", rt.FamilyID))
+
+		if rt.Revoked || time.Now().After(rt.ExpiresAt) {
+			writeError(w, r, http.StatusUnauthorized, codeInvalidToken, "Токен не действителен")
+			log.Info("Токен не обновлен: токен отозван или истек")
+			return
+		}
+
+		fresh, err := s.UsrStorage.UseRefreshToken(ctx, hash)
+		if err != nil {
+			writeError(w, r, http.StatusInternalServerError, codeInternal, "Ошибка при обращении к бд")
+			log.Info("Токен не обновлен: ошибка при обращении к бд", sl.Err(err))
+			return
+		}
+		if !fresh {
+			if err := s.UsrStorage.RevokeRefreshFamily(ctx, rt.FamilyID); err != nil {
+				log.Info("семейство токенов не отозвано", sl.Err(err))
+			}
+			writeError(w, r, http.StatusUnauthorized, codeInvalidToken, "Токен не действителен")
+			log.Warn("Токен не обновлен: повторное использование refresh-токена, семейство отозвано")
+			return
+		}
+
+		resp, err := s.issueTokens(ctx, models.User{ID: rt.UserID, Login: rt.Login}, rt.FamilyID)
+		if err != nil {
+			writeError(w, r, http.StatusInternalServerError, codeInternal, "Не удалось выпустить токен")
+			log.Info("Токен не обновлен: не удалось выпустить токены", sl.Err(err))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+		log.Info("Токен обновлен")
+	}
+}
+
+// LogoutRoot - отзывает семейство refresh-токена и текущий токен доступа
+func (s *Server) LogoutRoot() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "auth.LogoutRoot"
+		log := s.log.With(slog.String("op", op), slog.String("request_id", requestID(r)))
+		if r.Method != http.MethodPost {
+			methodNotAllowed(w, r, http.MethodPost)
+			log.Info("Выход не выполнен: Метод не поддерживается")
+			return
+		}
+
+		claims, err := s.tokens.Parse(getTokenFromHeader(r))
+		if err != nil {
+			writeTokenError(w, r, err)
+			log.Info("Выход не выполнен: ошибка при валидации токена", sl.Err(err))
+			return
+		}
+		log = log.With(slog.Int64("user_id", claims.UID))
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, codeBadRequest, "Ошибка при чтении тела запроса")
+			log.Info("Выход не выполнен: Ошибка при чтении тела запроса", sl.Err(err))
+			return
+		}
+		defer r.Body.Close()
+
+		ctx := r.Context()
+		var data refreshRequest
+		if len(body) > 0 {
+			if err := json.Unmarshal(body, &data); err != nil {
+				writeError(w, r, http.StatusBadRequest, codeBadRequest, "Ошибка при декодировании JSON")
+				log.Info("Выход не выполнен: Ошибка при декодировании JSON", sl.Err(err))
+				return
+			}
+		}
+		if data.RefreshToken != "" {
+			rt, err := s.UsrStorage.GetRefreshToken(ctx, hashRefreshToken(data.RefreshToken))
+			switch {
+			case errors.Is(err, storage.ErrRefreshTokenNotFound):
+				log.Info("refresh-токен при выходе не найден")
+			case err != nil:
+				writeError(w, r, http.StatusInternalServerError, codeInternal, "Ошибка при обращении к бд")
+				log.Info("Выход не выполнен: ошибка при обращении к бд", sl.Err(err))
+				return
+			case rt.UserID != claims.UID:
+				writeError(w, r, http.StatusForbidden, codeForbidden, "Refresh-токен принадлежит другому пользователю")
+				log.Info("Выход не выполнен: refresh-токен принадлежит другому пользователю")
+				return
+			default:
+				if err := s.UsrStorage.RevokeRefreshFamily(ctx, rt.FamilyID); err != nil {
+					writeError(w, r, http.StatusInternalServerError, codeInternal, "Ошибка при обращении к бд")
+					log.Info("Выход не выполнен: ошибка при обращении к бд", sl.Err(err))
+					return
+				}
+			}
+		}
+
+		if err := s.UsrStorage.RevokeAccessToken(ctx, claims.ID, claims.ExpiresAt.Time); err != nil {
+			writeError(w, r, http.StatusInternalServerError, codeInternal, "Ошибка при обращении к бд")
+			log.Info("Выход не выполнен: ошибка при обращении к бд", sl.Err(err))
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+		log.Info("Выход выполнен")
+	}
+}
+
+// issueTokens - выпускает токен доступа и refresh-токен семейства familyID
+// (новое семейство, если familyID пуст)
+func (s *Server) issueTokens(ctx context.Context, user models.User, familyID string) (ResponseToLogin, error) {
+	access, err := s.tokens.NewToken(user, s.tokenTTL)
+	if err != nil {
+		return ResponseToLogin{}, err
+	}
+
+	refresh, err := randomToken()
+	if err != nil {
+		return ResponseToLogin{}, err
+	}
+	if familyID == "" {
+		if familyID, err = randomToken(); err != nil {
+			return ResponseToLogin{}, err
+		}
+	}
+
+	rt := models.RefreshToken{
+		Hash:      hashRefreshToken(refresh),
+		UserID:    user.ID,
+		FamilyID:  familyID,
+		ExpiresAt: time.Now().Add(s.refreshTTL),
+	}
+	if err := s.UsrStorage.SaveRefreshToken(ctx, rt); err != nil {
+		return ResponseToLogin{}, err
+	}
+
+	return ResponseToLogin{Token: access, RefreshToken: refresh}, nil
+}
+
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("can't generate token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashRefreshToken - в бд хранится только хэш refresh-токена
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
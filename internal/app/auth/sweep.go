@@ -0,0 +1,407 @@
+package auth
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kms-qwe/DAEC/internal/expr"
+	"github.com/kms-qwe/DAEC/internal/lib/logger/sl"
+	"github.com/kms-qwe/DAEC/internal/storage"
+)
+
+// maxSweepSize - сколько выражений может получиться из одного перебора
+const maxSweepSize = 10000
+
+// Статусы перебора
+const (
+	sweepComputing = "computing"
+	sweepDone      = "done"
+)
+
+// SweepParam - значения параметра перебора: диапазон от From до To
+// включительно с шагом Step или явный список Values
+type SweepParam struct {
+	From   *float64  `json:"from,omitempty"`
+	To     *float64  `json:"to,omitempty"`
+	Step   *float64  `json:"step,omitempty"`
+	Values []float64 `json:"values,omitempty"`
+}
+
+type sweepRequest struct {
+	Expression string `json:"expression"`
+	// Bindings - значения переменных, общие для всех выражений перебора
+	Bindings map[string]float64    `json:"bindings,omitempty"`
+	Params   map[string]SweepParam `json:"params"`
+}
+
+// Sweep - перебор: шаблон выражения вычисляется для каждого сочетания
+// значений параметров, каждое сочетание - отдельное выражение
+type Sweep struct {
+	ID       int64                 `json:"id"`
+	Expr     string                `json:"expression"`
+	Bindings map[string]float64    `json:"bindings,omitempty"`
+	Params   map[string]SweepParam `json:"params"`
+	Status   string                `json:"status"`
+	Total    int                   `json:"total"`
+	// Progress - число выражений перебора по статусам
+	Progress  map[string]int `json:"progress"`
+	CreatedAt time.Time      `json:"created_at"`
+}
+
+type ResponseToNewSweep struct {
+	ID    int64 `json:"id"`
+	Total int   `json:"total"`
+}
+
+// SweepRow - строка результатов перебора: значения параметров и результат выражения
+type SweepRow struct {
+	ID     int64              `json:"id"`
+	Params map[string]float64 `json:"params"`
+	Status string             `json:"status"`
+	Result float64            `json:"result"`
+	Reason string             `json:"reason,omitempty"`
+}
+
+type ResponseToSweepResults struct {
+	ID     int64      `json:"id"`
+	Params []string   `json:"params"`
+	Rows   []SweepRow `json:"rows"`
+}
+
+// values - значения параметра; ошибка, если их больше limit
+func (p SweepParam) values(limit int) ([]float64, error) {
+	isRange := p.From != nil || p.To != nil || p.Step != nil
+	switch {
+	case isRange && len(p.Values) > 0:
+		return nil, fmt.Errorf("either from, to, step or values must be set")
+	case len(p.Values) > 0:
+		if len(p.Values) > limit {
+			return nil, fmt.Errorf("sweep would exceed %d expressions", maxSweepSize)
+		}
+		return p.Values, nil
+	case !isRange:
+		return nil, fmt.Errorf("from, to, step or values must be set")
+	case p.From == nil || p.To == nil || p.Step == nil:
+		return nil, fmt.Errorf("from, to and step must be set together")
+	case *p.Step <= 0:
+		return nil, fmt.Errorf("step must be positive")
+	case *p.To < *p.From:
+		return nil, fmt.Errorf("to must not be less than from")
+	}
+
+	// запас на погрешность, чтобы to попадал в диапазон: 0..1 с шагом 0.1 - 11 значений
+	n := math.Floor((*p.To - *p.From) / *p.Step * (1 + 1e-12))
+	if n+1 > float64(limit) {
+		return nil, fmt.Errorf("sweep would exceed %d expressions", maxSweepSize)
+	}
+	values := make([]float64, int(n)+1)
+	for i := range values {
+		// значение считается от from, чтобы не копить ошибку сложения, и
+		// округляется до 15 значащих цифр: 0.1 * 3 - это 0.3, а не 0.30000000000000004
+		v := *p.From + float64(i)**p.Step
+		values[i], _ = strconv.ParseFloat(strconv.FormatFloat(v, 'g', 15, 64), 64)
+	}
+	return values, nil
+}
+
+// expandSweep - проверяет перебор и строит по выражению на каждое сочетание
+// значений параметров. Параметры перебираются в порядке имен, последний
+// меняется быстрее всех. Сочетание, для которого выражение не определено
+// (например, деление на ноль), сохраняется сразу с ошибкой
+func expandSweep(req sweepRequest) ([]NewExpr, *ItemError) {
+	tree, err := expr.Parse(req.Expression)
+	if err != nil {
+		return nil, &ItemError{Code: codeInvalidExpression, Message: "Невалидное выражение: " + err.Error()}
+	}
+	if len(req.Params) == 0 {
+		return nil, &ItemError{Code: codeValidation, Message: "Нужен хотя бы один параметр перебора"}
+	}
+
+	names := make([]string, 0, len(req.Params))
+	for name := range req.Params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	given := make([]string, 0, len(req.Bindings)+len(names))
+	for name := range req.Bindings {
+		given = append(given, name)
+	}
+	sort.Strings(given)
+	bound := make(map[string]float64, len(req.Bindings)+len(names))
+	for _, name := range given {
+		if _, ok := req.Params[name]; ok {
+			return nil, &ItemError{Code: codeValidation, Message: fmt.Sprintf("Переменная %q задана и в bindings, и в params", name)}
+		}
+		bound[name] = req.Bindings[name]
+	}
+	if ierr := unknownVars(tree, append(given, names...)); ierr != nil {
+		return nil, ierr
+	}
+
+	grid := make([][]float64, len(names))
+	total := 1
+	for i, name := range names {
+		values, err := req.Params[name].values(maxSweepSize / total)
+		if err != nil {
+			return nil, &ItemError{Code: codeValidation, Message: fmt.Sprintf("Некорректный параметр %q: %s", name, err)}
+		}
+		grid[i] = values
+		total *= len(values)
+		bound[name] = 0
+	}
+	if errs := expr.Unbound(tree, bound); len(errs) > 0 {
+		msgs := make([]string, len(errs))
+		for i, e := range errs {
+			msgs[i] = e.Error()
+		}
+		return nil, &ItemError{Code: codeUnboundVariable, Message: "Не заданы значения переменных: " + strings.Join(msgs, "; ")}
+	}
+
+	exprs := make([]NewExpr, 0, total)
+	idx := make([]int, len(names))
+	for range total {
+		bindings := make(map[string]float64, len(bound))
+		for name, v := range req.Bindings {
+			bindings[name] = v
+		}
+		for i, name := range names {
+			bindings[name] = grid[i][idx[i]]
+		}
+
+		e := NewExpr{Expr: req.Expression, Bindings: bindings}
+		// Bind изменяет дерево, поэтому шаблон разбирается заново
+		child, _ := expr.Parse(req.Expression)
+		child, err := expr.Bind(child, bindings)
+		if err == nil {
+			e.PolishExpr, err = expr.Postfix(child)
+		}
+		if err != nil {
+			e.Reason = codeInvalidExpression + ": " + err.Error()
+		}
+		exprs = append(exprs, e)
+
+		for i := len(idx) - 1; i >= 0; i-- {
+			idx[i]++
+			if idx[i] < len(grid[i]) {
+				break
+			}
+			idx[i] = 0
+		}
+	}
+	return exprs, nil
+}
+
+// SweepsRoot - принимает перебор: выражения всех сочетаний сохраняются одной транзакцией
+func (s *Server) SweepsRoot() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "auth.SweepsRoot"
+		log := s.log.With(slog.String("op", op), slog.String("request_id", requestID(r)))
+		if r.Method != http.MethodPost {
+			methodNotAllowed(w, r, http.MethodPost)
+			log.Info("Перебор не принят: Метод не поддерживается")
+			return
+		}
+
+		userID, err := s.validateJWTToken(r)
+		if err != nil {
+			writeTokenError(w, r, err)
+			log.Info("Перебор не принят: ошибка при валидации токена", sl.Err(err))
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBatchBody))
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, codeBadRequest, "Ошибка при чтении тела запроса")
+			log.Info("Перебор не принят: Ошибка при чтении тела запроса", sl.Err(err))
+			return
+		}
+		defer r.Body.Close()
+
+		var data sweepRequest
+		if err := json.Unmarshal(body, &data); err != nil {
+			writeError(w, r, http.StatusBadRequest, codeBadRequest, "Ошибка при декодировании JSON")
+			log.Info("Перебор не принят: Ошибка при декодировании JSON", sl.Err(err))
+			return
+		}
+
+		exprs, itemErr := expandSweep(data)
+		if itemErr != nil {
+			writeError(w, r, http.StatusUnprocessableEntity, itemErr.Code, itemErr.Message)
+			log.Info("Перебор не принят: Невалидные данные", slog.String("reason", itemErr.Message))
+			return
+		}
+
+		sweep := Sweep{Expr: data.Expression, Bindings: data.Bindings, Params: data.Params, Total: len(exprs)}
+		id, err := s.UsrStorage.SaveSweep(r.Context(), userID, sweep, exprs)
+		if err != nil {
+			writeError(w, r, http.StatusInternalServerError, codeInternal, "Ошибка при обращении к бд")
+			log.Info("Перебор не принят: ошибка при обращении к бд", sl.Err(err))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(ResponseToNewSweep{ID: id, Total: len(exprs)}); err != nil {
+			log.Info("Перебор принят, но id не отдан: ошибка при записи", sl.Err(err))
+		}
+		log.Info("Перебор принят на вычисление", slog.Int64("id", id), slog.Int("total", len(exprs)))
+	}
+}
+
+// SweepRoot - перебор пользователя и число его выражений по статусам
+func (s *Server) SweepRoot() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "auth.SweepRoot"
+		log := s.log.With(slog.String("op", op), slog.String("request_id", requestID(r)))
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, r, http.MethodGet)
+			log.Info("Перебор не отдан: Метод не поддерживается")
+			return
+		}
+
+		sweep, ok := s.sweepFromRequest(w, r, log)
+		if !ok {
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(sweep); err != nil {
+			log.Info("Перебор не отдан: ошибка при записи", sl.Err(err))
+		}
+	}
+}
+
+// SweepResultsRoot - результаты перебора таблицей: JSON или CSV (?format=csv
+// или Accept: text/csv). Результаты отдаются, когда вычислены все выражения
+func (s *Server) SweepResultsRoot() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "auth.SweepResultsRoot"
+		log := s.log.With(slog.String("op", op), slog.String("request_id", requestID(r)))
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, r, http.MethodGet)
+			log.Info("Результаты перебора не отданы: Метод не поддерживается")
+			return
+		}
+
+		format := r.URL.Query().Get("format")
+		if format == "" && strings.Contains(r.Header.Get("Accept"), "text/csv") {
+			format = "csv"
+		}
+		if format != "" && format != "json" && format != "csv" {
+			writeError(w, r, http.StatusBadRequest, codeBadRequest, "format должен быть json или csv")
+			log.Info("Результаты перебора не отданы: некорректный format", slog.String("format", format))
+			return
+		}
+
+		sweep, ok := s.sweepFromRequest(w, r, log)
+		if !ok {
+			return
+		}
+		if sweep.Status != sweepDone {
+			writeError(w, r, http.StatusConflict, codeSweepInProgress, "Перебор еще вычисляется")
+			log.Info("Результаты перебора не отданы: перебор еще вычисляется", slog.Int64("id", sweep.ID))
+			return
+		}
+
+		exprs, err := s.UsrStorage.GetSweepExprs(r.Context(), sweep.ID)
+		if err != nil {
+			writeError(w, r, http.StatusInternalServerError, codeInternal, "Ошибка при обращении к бд")
+			log.Info("Результаты перебора не отданы: ошибка при обращении к бд", sl.Err(err))
+			return
+		}
+
+		ans := ResponseToSweepResults{ID: sweep.ID, Rows: make([]SweepRow, 0, len(exprs))}
+		for name := range sweep.Params {
+			ans.Params = append(ans.Params, name)
+		}
+		sort.Strings(ans.Params)
+		for _, e := range exprs {
+			row := SweepRow{ID: e.Id, Params: make(map[string]float64, len(ans.Params)), Status: e.Status, Result: e.Result, Reason: e.Reason}
+			for _, name := range ans.Params {
+				row.Params[name] = e.Bindings[name]
+			}
+			ans.Rows = append(ans.Rows, row)
+		}
+
+		if format == "csv" {
+			w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+			w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="sweep-%d.csv"`, sweep.ID))
+			if err := writeSweepCSV(w, ans); err != nil {
+				log.Info("Результаты перебора не отданы: ошибка при записи", sl.Err(err))
+			}
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(ans); err != nil {
+			log.Info("Результаты перебора не отданы: ошибка при записи", sl.Err(err))
+		}
+	}
+}
+
+// sweepFromRequest - перебор пользователя по ?id=; при ошибке ответ уже записан
+func (s *Server) sweepFromRequest(w http.ResponseWriter, r *http.Request, log *slog.Logger) (Sweep, bool) {
+	userID, err := s.validateJWTToken(r)
+	if err != nil {
+		writeTokenError(w, r, err)
+		log.Info("Перебор не отдан: ошибка при валидации токена", sl.Err(err))
+		return Sweep{}, false
+	}
+
+	id, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, codeBadRequest, "Некорректный id")
+		log.Info("Перебор не отдан: ошибка при получении id", sl.Err(err))
+		return Sweep{}, false
+	}
+
+	sweep, err := s.UsrStorage.GetSweep(r.Context(), id, userID)
+	if errors.Is(err, storage.ErrSweepNotFound) {
+		writeError(w, r, http.StatusNotFound, codeNotFound, "Перебор не найден")
+		log.Info("Перебор не отдан: нет записей", slog.Int64("id", id))
+		return Sweep{}, false
+	}
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, codeInternal, "Ошибка при обращении к бд")
+		log.Info("Перебор не отдан: ошибка при обращении к бд", sl.Err(err))
+		return Sweep{}, false
+	}
+
+	sweep.Status = sweepDone
+	if sweep.Progress[exprComputing] > 0 {
+		sweep.Status = sweepComputing
+	}
+	return sweep, true
+}
+
+// writeSweepCSV - столбцы: id, параметры в порядке имен, status, result, reason
+func writeSweepCSV(w io.Writer, res ResponseToSweepResults) error {
+	cw := csv.NewWriter(w)
+	header := append([]string{"id"}, res.Params...)
+	header = append(header, "status", "result", "reason")
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	for _, row := range res.Rows {
+		rec := make([]string, 0, len(header))
+		rec = append(rec, strconv.FormatInt(row.ID, 10))
+		for _, name := range res.Params {
+			rec = append(rec, strconv.FormatFloat(row.Params[name], 'g', -1, 64))
+		}
+		rec = append(rec, row.Status, strconv.FormatFloat(row.Result, 'g', -1, 64), row.Reason)
+		if err := cw.Write(rec); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,205 @@
+package auth_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kms-qwe/DAEC/internal/app/auth"
+)
+
+// newSweep - POST /api/v1/sweeps
+func (f *watchFixture) newSweep(body string) *httptest.ResponseRecorder {
+	return serveURL(f.s.SweepsRoot(), http.MethodPost, "/", f.token, body)
+}
+
+func decodeSweep(t *testing.T, rec *httptest.ResponseRecorder) auth.ResponseToNewSweep {
+	t.Helper()
+	if rec.Code != http.StatusOK {
+		t.Fatalf("sweep = %d %q, want 200", rec.Code, rec.Body.String())
+	}
+	var resp auth.ResponseToNewSweep
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode %q: %v", rec.Body.String(), err)
+	}
+	return resp
+}
+
+// getSweep - GET /api/v1/sweep
+func (f *watchFixture) getSweep(t *testing.T, id int64) auth.Sweep {
+	t.Helper()
+	rec := serveURL(f.s.SweepRoot(), http.MethodGet, fmt.Sprintf("/?id=%d", id), f.token, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("get sweep = %d %q, want 200", rec.Code, rec.Body.String())
+	}
+	var sweep auth.Sweep
+	if err := json.Unmarshal(rec.Body.Bytes(), &sweep); err != nil {
+		t.Fatalf("decode %q: %v", rec.Body.String(), err)
+	}
+	return sweep
+}
+
+// sweepExprIDs - id выражений перебора в порядке создания
+func (f *watchFixture) sweepExprIDs(t *testing.T, id int64) []int64 {
+	t.Helper()
+	rows, err := f.db.Query(`SELECT expr_id FROM expressions WHERE sweep_id = ? ORDER BY expr_id`, id)
+	if err != nil {
+		t.Fatalf("select sweep exprs: %v", err)
+	}
+	defer rows.Close()
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			t.Fatalf("scan: %v", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func TestSweepProgressAndCSV(t *testing.T) {
+	f := newWatchFixture(t)
+	resp := decodeSweep(t, f.newSweep(`{"expression":"x / y","params":{"y":{"values":[0,2]},"x":{"from":0,"to":0.2,"step":0.1}}}`))
+	if resp.Total != 6 {
+		t.Fatalf("total = %d, want 6", resp.Total)
+	}
+
+	// сочетания с y = 0 сохраняются сразу с ошибкой
+	sweep := f.getSweep(t, resp.ID)
+	if sweep.Status != "computing" || sweep.Progress["computing"] != 3 || sweep.Progress["error"] != 3 {
+		t.Errorf("sweep = %s %v, want computing with 3 computing and 3 error", sweep.Status, sweep.Progress)
+	}
+	target := fmt.Sprintf("/?id=%d&format=csv", resp.ID)
+	wantError(t, serveURL(f.s.SweepResultsRoot(), http.MethodGet, target, f.token, ""), http.StatusConflict, "sweep_in_progress")
+
+	f.exec(t, `UPDATE expressions SET status = 'done', result = 0.5 WHERE sweep_id = ? AND status = 'computing'`, resp.ID)
+	sweep = f.getSweep(t, resp.ID)
+	if sweep.Status != "done" || sweep.Progress["done"] != 3 || sweep.Progress["computing"] != 0 {
+		t.Errorf("sweep = %s %v, want done with 3 done", sweep.Status, sweep.Progress)
+	}
+
+	rec := serveURL(f.s.SweepResultsRoot(), http.MethodGet, target, f.token, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("results = %d %q, want 200", rec.Code, rec.Body.String())
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "text/csv; charset=utf-8" {
+		t.Errorf("Content-Type = %q, want text/csv", ct)
+	}
+	ids := f.sweepExprIDs(t, resp.ID)
+	var want strings.Builder
+	want.WriteString("id,x,y,status,result,reason\n")
+	for i, xy := range [][2]string{{"0", "0"}, {"0", "2"}, {"0.1", "0"}, {"0.1", "2"}, {"0.2", "0"}, {"0.2", "2"}} {
+		if xy[1] == "0" {
+			fmt.Fprintf(&want, "%d,%s,0,error,0,invalid_expression: pos 4: division by zero\n", ids[i], xy[0])
+		} else {
+			fmt.Fprintf(&want, "%d,%s,2,done,0.5,\n", ids[i], xy[0])
+		}
+	}
+	if got := rec.Body.String(); got != want.String() {
+		t.Errorf("csv =\n%s\nwant\n%s", got, want.String())
+	}
+}
+
+func TestSweepDeleteMember(t *testing.T) {
+	f := newWatchFixture(t)
+	resp := decodeSweep(t, f.newSweep(`{"expression":"x + 1","params":{"x":{"values":[1,2,3]}}}`))
+	ids := f.sweepExprIDs(t, resp.ID)
+
+	rec := serveURL(f.s.ExprByIdRoot(), http.MethodDelete, fmt.Sprintf("/?id=%d", ids[1]), f.token, "")
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("delete = %d %q, want 204", rec.Code, rec.Body.String())
+	}
+	sweep := f.getSweep(t, resp.ID)
+	if sweep.Total != 2 || sweep.Progress["computing"] != 2 {
+		t.Errorf("sweep = total %d %v, want total 2 with 2 computing", sweep.Total, sweep.Progress)
+	}
+
+	f.exec(t, `UPDATE expressions SET status = 'done', result = 1 WHERE sweep_id = ?`, resp.ID)
+	rec = serveURL(f.s.SweepResultsRoot(), http.MethodGet, fmt.Sprintf("/?id=%d", resp.ID), f.token, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("results = %d %q, want 200", rec.Code, rec.Body.String())
+	}
+	var res auth.ResponseToSweepResults
+	if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+		t.Fatalf("decode %q: %v", rec.Body.String(), err)
+	}
+	if len(res.Rows) != 2 || res.Rows[0].ID != ids[0] || res.Rows[1].ID != ids[2] {
+		t.Errorf("rows = %+v, want %d and %d", res.Rows, ids[0], ids[2])
+	}
+}
+
+func TestSweepLimits(t *testing.T) {
+	f := newWatchFixture(t)
+
+	// ровно maxSweepSize сочетаний принимается
+	if resp := decodeSweep(t, f.newSweep(`{"expression":"x * y","params":{"x":{"from":1,"to":100,"step":1},"y":{"from":1,"to":100,"step":1}}}`)); resp.Total != 10000 {
+		t.Errorf("total = %d, want 10000", resp.Total)
+	}
+
+	tests := []struct {
+		body string
+		code string
+		msg  string
+	}{
+		{
+			body: `{"expression":"x","params":{"x":{"from":0,"to":10000,"step":1}}}`,
+			code: "validation_failed",
+			msg:  `Некорректный параметр "x": sweep would exceed 10000 expressions`,
+		},
+		{
+			body: `{"expression":"x * y","params":{"x":{"from":1,"to":200,"step":1},"y":{"from":1,"to":51,"step":1}}}`,
+			code: "validation_failed",
+			msg:  `Некорректный параметр "y": sweep would exceed 10000 expressions`,
+		},
+		{
+			body: `{"expression":"x","params":{"x":{"from":0,"to":1,"step":0}}}`,
+			code: "validation_failed",
+			msg:  `Некорректный параметр "x": step must be positive`,
+		},
+		{
+			body: `{"expression":"x","params":{"x":{"from":1,"to":0,"step":1}}}`,
+			code: "validation_failed",
+			msg:  `Некорректный параметр "x": to must not be less than from`,
+		},
+		{
+			body: `{"expression":"x","params":{"x":{"from":0,"to":1,"step":1,"values":[1]}}}`,
+			code: "validation_failed",
+			msg:  `Некорректный параметр "x": either from, to, step or values must be set`,
+		},
+		{
+			body: `{"expression":"x","params":{}}`,
+			code: "validation_failed",
+			msg:  "Нужен хотя бы один параметр перебора",
+		},
+		{
+			body: `{"expression":"x","bindings":{"x":1},"params":{"x":{"values":[1]}}}`,
+			code: "validation_failed",
+			msg:  `Переменная "x" задана и в bindings, и в params`,
+		},
+		{
+			body: `{"expression":"x","bindings":{"b":1},"params":{"x":{"values":[1]},"a":{"values":[1]}}}`,
+			code: "validation_failed",
+			msg:  `Переменных "a", "b" нет в выражении`,
+		},
+		{
+			body: `{"expression":"x + y","params":{"x":{"values":[1]}}}`,
+			code: "unbound_variable",
+			msg:  `Не заданы значения переменных: pos 4: unbound variable "y"`,
+		},
+	}
+	for _, tt := range tests {
+		rec := f.newSweep(tt.body)
+		wantError(t, rec, http.StatusUnprocessableEntity, tt.code)
+		var resp auth.ErrorResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decode %q: %v", rec.Body.String(), err)
+		}
+		if resp.Error.Message != tt.msg {
+			t.Errorf("%s: message = %q, want %q", tt.body, resp.Error.Message, tt.msg)
+		}
+	}
+}
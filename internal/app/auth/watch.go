@@ -0,0 +1,237 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/kms-qwe/DAEC/internal/lib/logger/sl"
+)
+
+// exprComputing - статус выражения, которое еще вычисляется
+const exprComputing = "computing"
+
+const (
+	// maxWait - дольше запрос выражения не ждет
+	maxWait = 60 * time.Second
+	// keepAliveInterval - как часто поток событий шлет комментарий, чтобы прокси не закрыли соединение
+	keepAliveInterval = 15 * time.Second
+)
+
+// ExprState - состояние выражения в потоке событий: статус и сколько его операций вычислено
+type ExprState struct {
+	Id         int64   `json:"id"`
+	Status     string  `json:"status"`
+	Result     float64 `json:"result"`
+	Reason     string  `json:"reason,omitempty"`
+	TasksDone  int     `json:"tasks_done"`
+	TasksTotal int     `json:"tasks_total"`
+}
+
+// parseWait - время ожидания из параметра wait: длительность (30s) или число секунд
+func parseWait(v string) (time.Duration, error) {
+	if v == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		sec, errSec := strconv.Atoi(v)
+		if errSec != nil {
+			return 0, err
+		}
+		d = time.Duration(sec) * time.Second
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("negative duration %s", v)
+	}
+	return min(d, maxWait), nil
+}
+
+// waitExpr - ждет, пока статус вычисляемого выражения не изменится, но не дольше wait.
+// По истечении wait или при остановке сервера возвращает текущее состояние
+func (s *Server) waitExpr(ctx context.Context, expr Expr, userID int64, wait time.Duration) (Expr, error) {
+	if expr.Status != exprComputing {
+		return expr, nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	ticker := time.NewTicker(s.watchInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-timer.C:
+			return expr, nil
+		case <-s.shutdown:
+			return expr, nil
+		case <-ctx.Done():
+			return expr, ctx.Err()
+		}
+
+		cur, err := s.UsrStorage.GetById(ctx, expr.Id, userID)
+		if err != nil {
+			return expr, err
+		}
+		if cur.Status != expr.Status {
+			return cur, nil
+		}
+		expr = cur
+	}
+}
+
+// EventsRoot - поток Server-Sent Events по выражениям пользователя: событие status
+// при появлении выражения и смене его статуса, событие progress при вычислении
+// очередной операции. Уже завершенные к подключению выражения не присылаются.
+// id события - курсор опроса: клиент, переподключившийся с Last-Event-ID,
+// получает текущее состояние всех выражений начиная с курсора, в том числе
+// завершенных, так что события за время разрыва не теряются
+func (s *Server) EventsRoot() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "auth.EventsRoot"
+		log := s.log.With(slog.String("op", op), slog.String("request_id", requestID(r)))
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, r, http.MethodGet)
+			log.Info("Поток событий не открыт: Метод не поддерживается")
+			return
+		}
+
+		userID, err := s.validateJWTToken(r)
+		if err != nil {
+			writeTokenError(w, r, err)
+			log.Info("Поток событий не открыт: ошибка при валидации токена", sl.Err(err))
+			return
+		}
+
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			writeError(w, r, http.StatusInternalServerError, codeInternal, "Поток событий не поддерживается")
+			log.Info("Поток событий не открыт: ResponseWriter не поддерживает Flush")
+			return
+		}
+
+		since, resume, err := parseLastEventID(r.Header.Get("Last-Event-ID"))
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, codeBadRequest, "Некорректный Last-Event-ID")
+			log.Info("Поток событий не открыт: некорректный Last-Event-ID", sl.Err(err))
+			return
+		}
+		if !resume {
+			// опрос начинается с самого старого вычисляемого выражения: более ранние
+			// уже завершены и не присылаются, так что всю историю читать не нужно
+			since, err = s.UsrStorage.GetEventsCursor(r.Context(), userID)
+			if err != nil {
+				writeError(w, r, http.StatusInternalServerError, codeInternal, "Ошибка при обращении к бд")
+				log.Info("Поток событий не открыт: ошибка при обращении к бд", sl.Err(err))
+				return
+			}
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+		log = log.With(slog.Int64("user_id", userID))
+		log.Info("Поток событий открыт", slog.Int64("since", since), slog.Bool("resume", resume))
+
+		ctx := r.Context()
+		ticker := time.NewTicker(s.watchInterval)
+		defer ticker.Stop()
+		keepAlive := time.NewTicker(keepAliveInterval)
+		defer keepAlive.Stop()
+
+		// states - последнее отправленное состояние выражений с id >= since
+		states := map[int64]ExprState{}
+		skipFinished := !resume
+		for {
+			// события прохода помечаются курсором, с которого он начат
+			cursor := since
+			cur, err := s.UsrStorage.GetExprStates(ctx, userID, since)
+			if err != nil {
+				log.Info("Поток событий закрыт: ошибка при обращении к бд", sl.Err(err))
+				return
+			}
+
+			next := int64(-1)
+			for _, st := range cur {
+				prev, seen := states[st.Id]
+				switch {
+				case !seen && skipFinished && st.Status != exprComputing:
+					// завершено до подключения
+				case !seen || prev.Status != st.Status:
+					err = writeEvent(w, "status", cursor, st)
+				case prev.TasksDone != st.TasksDone:
+					err = writeEvent(w, "progress", cursor, st)
+				}
+				if err != nil {
+					log.Info("Поток событий закрыт: ошибка записи", sl.Err(err))
+					return
+				}
+				states[st.Id] = st
+
+				if st.Status == exprComputing && (next < 0 || st.Id < next) {
+					next = st.Id
+				}
+				since = max(since, st.Id+1)
+			}
+			// следующий опрос начинается с самого старого вычисляемого выражения
+			if next >= 0 {
+				since = next
+			}
+			for id := range states {
+				if id < since {
+					delete(states, id)
+				}
+			}
+			skipFinished = false
+			flusher.Flush()
+
+			select {
+			case <-ticker.C:
+			case <-keepAlive.C:
+				if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+					log.Info("Поток событий закрыт: ошибка записи", sl.Err(err))
+					return
+				}
+			case <-s.shutdown:
+				log.Info("Поток событий закрыт: сервер останавливается")
+				return
+			case <-ctx.Done():
+				log.Info("Поток событий закрыт клиентом")
+				return
+			}
+		}
+	}
+}
+
+// parseLastEventID - курсор из заголовка Last-Event-ID; resume = false, если заголовка нет
+func parseLastEventID(v string) (since int64, resume bool, err error) {
+	if v == "" {
+		return 0, false, nil
+	}
+	since, err = strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return 0, false, err
+	}
+	if since < 0 {
+		return 0, false, fmt.Errorf("negative id %d", since)
+	}
+	return since, true, nil
+}
+
+// writeEvent - записывает событие SSE с курсором в id и состоянием выражения в data
+func writeEvent(w http.ResponseWriter, event string, id int64, st ExprState) error {
+	data, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", id, event, data)
+	return err
+}
@@ -0,0 +1,241 @@
+package auth_test
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kms-qwe/DAEC/internal/app/auth"
+	"github.com/kms-qwe/DAEC/internal/storage/sqlite"
+)
+
+// watchFixture - пользователь с токеном и бд, в которой тест играет роль оркестратора
+type watchFixture struct {
+	s      *auth.Server
+	token  string
+	userID int64
+	st     *sqlite.AuthStorage
+	db     *sql.DB
+}
+
+func newWatchFixture(t *testing.T) *watchFixture {
+	t.Helper()
+	s, st, path := newTestServerAt(t)
+	tokens := login(t, s, "user")
+	_, userID, err := st.GetPassword(context.Background(), "user")
+	if err != nil {
+		t.Fatalf("GetPassword: %v", err)
+	}
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return &watchFixture{s: s, token: tokens.Token, userID: userID, st: st, db: db}
+}
+
+// addExpr - сохраняет вычисляемое выражение пользователя
+func (f *watchFixture) addExpr(t *testing.T) int64 {
+	t.Helper()
+	id, err := f.st.SaveNewExpr(context.Background(), f.userID, auth.NewExpr{Expr: "1 + 1", PolishExpr: "1 1 +"})
+	if err != nil {
+		t.Fatalf("SaveNewExpr: %v", err)
+	}
+	return id
+}
+
+func (f *watchFixture) exec(t *testing.T, q string, args ...any) {
+	t.Helper()
+	if _, err := f.db.Exec(q, args...); err != nil {
+		t.Fatalf("%s: %v", q, err)
+	}
+}
+
+// getExpr - GET /api/v1/expression с ожиданием wait
+func (f *watchFixture) getExpr(id int64, wait string) *httptest.ResponseRecorder {
+	return serveURL(f.s.ExprByIdRoot(), http.MethodGet, fmt.Sprintf("/?id=%d&wait=%s", id, wait), f.token, "")
+}
+
+func decodeExpr(t *testing.T, rec *httptest.ResponseRecorder) auth.Expr {
+	t.Helper()
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d %q, want 200", rec.Code, rec.Body.String())
+	}
+	var resp auth.ResponseToGiveAllExpr
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || len(resp.Exprs) != 1 {
+		t.Fatalf("decode %q: %v", rec.Body.String(), err)
+	}
+	return resp.Exprs[0]
+}
+
+func TestWaitTimeout(t *testing.T) {
+	f := newWatchFixture(t)
+	id := f.addExpr(t)
+
+	start := time.Now()
+	e := decodeExpr(t, f.getExpr(id, "200ms"))
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("returned after %v, want to wait 200ms", elapsed)
+	}
+	if e.Status != "computing" {
+		t.Errorf("status = %q, want computing", e.Status)
+	}
+}
+
+func TestWaitStatusChange(t *testing.T) {
+	f := newWatchFixture(t)
+	id := f.addExpr(t)
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- f.getExpr(id, "5s") }()
+	time.Sleep(50 * time.Millisecond)
+	f.exec(t, `UPDATE expressions SET status = 'done', result = 2 WHERE expr_id = ?`, id)
+
+	select {
+	case rec := <-done:
+		if e := decodeExpr(t, rec); e.Status != "done" || e.Result != 2 {
+			t.Errorf("expr = %+v, want done with 2", e)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("wait did not return after the status change")
+	}
+}
+
+func TestWaitExprDeleted(t *testing.T) {
+	f := newWatchFixture(t)
+	id := f.addExpr(t)
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- f.getExpr(id, "5s") }()
+	time.Sleep(50 * time.Millisecond)
+	f.exec(t, `DELETE FROM expressions WHERE expr_id = ?`, id)
+
+	select {
+	case rec := <-done:
+		wantError(t, rec, http.StatusNotFound, "not_found")
+	case <-time.After(2 * time.Second):
+		t.Fatal("wait did not return after the expression was deleted")
+	}
+}
+
+func TestWaitBadDuration(t *testing.T) {
+	f := newWatchFixture(t)
+	id := f.addExpr(t)
+	wantError(t, f.getExpr(id, "-1s"), http.StatusBadRequest, "bad_request")
+}
+
+// sseEvent - событие потока: курсор, тип и выражение из data
+type sseEvent struct {
+	id    string
+	event string
+	state auth.ExprState
+}
+
+// readEvents - открывает поток событий на время d и разбирает полученные события
+func (f *watchFixture) readEvents(t *testing.T, lastEventID string, d time.Duration) []sseEvent {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+	req := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)
+	req.Header.Set("Authorization", "Bearer "+f.token)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	rec := httptest.NewRecorder()
+	f.s.EventsRoot()(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d %q, want 200", rec.Code, rec.Body.String())
+	}
+	var events []sseEvent
+	for _, block := range strings.Split(rec.Body.String(), "\n\n") {
+		var ev sseEvent
+		for _, line := range strings.Split(block, "\n") {
+			k, v, _ := strings.Cut(line, ": ")
+			switch k {
+			case "id":
+				ev.id = v
+			case "event":
+				ev.event = v
+			case "data":
+				if err := json.Unmarshal([]byte(v), &ev.state); err != nil {
+					t.Fatalf("decode data %q: %v", v, err)
+				}
+			}
+		}
+		if ev.event != "" {
+			events = append(events, ev)
+		}
+	}
+	return events
+}
+
+func statusIDs(events []sseEvent) []int64 {
+	var res []int64
+	for _, ev := range events {
+		if ev.event == "status" {
+			res = append(res, ev.state.Id)
+		}
+	}
+	return res
+}
+
+func TestEventsSkipFinished(t *testing.T) {
+	f := newWatchFixture(t)
+	first, second, third := f.addExpr(t), f.addExpr(t), f.addExpr(t)
+	f.exec(t, `UPDATE expressions SET status = 'done' WHERE expr_id IN (?, ?)`, first, second)
+
+	events := f.readEvents(t, "", 100*time.Millisecond)
+	if got := statusIDs(events); len(got) != 1 || got[0] != third {
+		t.Fatalf("status events for %v, want only [%d]", got, third)
+	}
+	if events[0].id != fmt.Sprint(third) {
+		t.Errorf("event id = %q, want the cursor %d", events[0].id, third)
+	}
+}
+
+func TestEventsResume(t *testing.T) {
+	f := newWatchFixture(t)
+	first, second, third := f.addExpr(t), f.addExpr(t), f.addExpr(t)
+
+	// клиент получил события с курсором first, затем соединение оборвалось,
+	// а за время разрыва second вычислилось
+	f.exec(t, `UPDATE expressions SET status = 'done', result = 2 WHERE expr_id = ?`, second)
+
+	events := f.readEvents(t, fmt.Sprint(first), 100*time.Millisecond)
+	got := statusIDs(events)
+	if len(got) != 3 || got[0] != first || got[1] != second || got[2] != third {
+		t.Fatalf("status events for %v, want [%d %d %d]", got, first, second, third)
+	}
+	if events[1].state.Status != "done" || events[1].state.Result != 2 {
+		t.Errorf("resumed state of %d = %+v, want done with 2", second, events[1].state)
+	}
+	for _, ev := range events {
+		if ev.id != fmt.Sprint(first) {
+			t.Errorf("event id = %q, want the cursor %d", ev.id, first)
+		}
+	}
+
+	// курсор после первого вычисляемого выражения: более ранние не присылаются
+	if got := statusIDs(f.readEvents(t, fmt.Sprint(third), 100*time.Millisecond)); len(got) != 1 || got[0] != third {
+		t.Errorf("status events for %v, want only [%d]", got, third)
+	}
+}
+
+func TestEventsBadLastEventID(t *testing.T) {
+	f := newWatchFixture(t)
+	for _, id := range []string{"abc", "-1"} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", "Bearer "+f.token)
+		req.Header.Set("Last-Event-ID", id)
+		rec := httptest.NewRecorder()
+		f.s.EventsRoot()(rec, req)
+		wantError(t, rec, http.StatusBadRequest, "bad_request")
+	}
+}
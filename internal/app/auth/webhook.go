@@ -0,0 +1,175 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/kms-qwe/DAEC/internal/lib/logger/sl"
+	"github.com/kms-qwe/DAEC/internal/storage"
+)
+
+// Settings - настройки пользователя. CallbackURL получает вебхуки о завершении
+// выражений, для которых в запросе не указан свой callback_url
+type Settings struct {
+	CallbackURL string `json:"callback_url"`
+}
+
+// WebhookDelivery - вебхук выражения и журнал попыток его доставки
+type WebhookDelivery struct {
+	ID            int64               `json:"id"`
+	Event         string              `json:"event"`
+	URL           string              `json:"url"`
+	Status        string              `json:"status"`
+	NextAttemptAt *time.Time          `json:"next_attempt_at,omitempty"`
+	CreatedAt     time.Time           `json:"created_at"`
+	Attempts      []WebhookAttemptLog `json:"attempts"`
+}
+
+type WebhookAttemptLog struct {
+	Attempt    int       `json:"attempt"`
+	At         time.Time `json:"at"`
+	StatusCode int       `json:"status_code,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	DurationMs int64     `json:"duration_ms"`
+}
+
+type ResponseToWebhooks struct {
+	Deliveries []WebhookDelivery `json:"deliveries"`
+}
+
+// validateCallbackURL - адрес вебхука должен быть абсолютным http(s) адресом
+func validateCallbackURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("scheme must be http or https")
+	}
+	if u.Host == "" {
+		return fmt.Errorf("host is empty")
+	}
+	return nil
+}
+
+// SettingsRoot - GET возвращает настройки пользователя, PUT заменяет их
+func (s *Server) SettingsRoot() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "auth.SettingsRoot"
+		log := s.log.With(slog.String("op", op), slog.String("request_id", requestID(r)))
+		if r.Method != http.MethodGet && r.Method != http.MethodPut {
+			methodNotAllowed(w, r, http.MethodGet, http.MethodPut)
+			log.Info("Настройки не обработаны: Метод не поддерживается")
+			return
+		}
+
+		userID, err := s.validateJWTToken(r)
+		if err != nil {
+			writeTokenError(w, r, err)
+			log.Info("Настройки не обработаны: ошибка при валидации токена", sl.Err(err))
+			return
+		}
+		log = log.With(slog.Int64("user_id", userID))
+
+		if r.Method == http.MethodPut {
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				writeError(w, r, http.StatusBadRequest, codeBadRequest, "Ошибка при чтении тела запроса")
+				log.Info("Настройки не сохранены: Ошибка при чтении тела запроса", sl.Err(err))
+				return
+			}
+			defer r.Body.Close()
+
+			var settings Settings
+			if err := json.Unmarshal(body, &settings); err != nil {
+				writeError(w, r, http.StatusBadRequest, codeBadRequest, "Ошибка при декодировании JSON")
+				log.Info("Настройки не сохранены: Ошибка при декодировании JSON", sl.Err(err))
+				return
+			}
+			if settings.CallbackURL != "" {
+				if err := validateCallbackURL(settings.CallbackURL); err != nil {
+					writeError(w, r, http.StatusUnprocessableEntity, codeValidation, "Некорректный callback_url: "+err.Error())
+					log.Info("Настройки не сохранены: некорректный callback_url", sl.Err(err))
+					return
+				}
+			}
+			if err := s.UsrStorage.SaveSettings(r.Context(), userID, settings); err != nil {
+				writeError(w, r, http.StatusInternalServerError, codeInternal, "Ошибка при обращении к бд")
+				log.Info("Настройки не сохранены: ошибка при обращении к бд", sl.Err(err))
+				return
+			}
+			log.Info("Настройки сохранены")
+		}
+
+		settings, err := s.UsrStorage.GetSettings(r.Context(), userID)
+		if err != nil {
+			writeError(w, r, http.StatusInternalServerError, codeInternal, "Ошибка при обращении к бд")
+			log.Info("Настройки не отданы: ошибка при обращении к бд", sl.Err(err))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(settings); err != nil {
+			log.Info("Настройки не отданы: ошибка при записи", sl.Err(err))
+		}
+	}
+}
+
+// WebhooksRoot - вебхуки выражения пользователя и журнал попыток их доставки
+func (s *Server) WebhooksRoot() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "auth.WebhooksRoot"
+		log := s.log.With(slog.String("op", op), slog.String("request_id", requestID(r)))
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, r, http.MethodGet)
+			log.Info("Вебхуки не отданы: Метод не поддерживается")
+			return
+		}
+
+		userID, err := s.validateJWTToken(r)
+		if err != nil {
+			writeTokenError(w, r, err)
+			log.Info("Вебхуки не отданы: ошибка при валидации токена", sl.Err(err))
+			return
+		}
+
+		id, err := strconv.Atoi(r.URL.Query().Get("id"))
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, codeBadRequest, "Некорректный id")
+			log.Info("Вебхуки не отданы: ошибка при получении id", sl.Err(err))
+			return
+		}
+
+		// выражение должно принадлежать пользователю
+		_, err = s.UsrStorage.GetById(r.Context(), int64(id), userID)
+		if errors.Is(err, storage.ErrExprNotFound) {
+			writeError(w, r, http.StatusNotFound, codeNotFound, "Выражение не найдено")
+			log.Info("Вебхуки не отданы: нет записей", slog.Int("id", id))
+			return
+		}
+		if err != nil {
+			writeError(w, r, http.StatusInternalServerError, codeInternal, "Ошибка при обращении к бд")
+			log.Info("Вебхуки не отданы: ошибка при обращении к бд", sl.Err(err))
+			return
+		}
+
+		deliveries, err := s.UsrStorage.GetWebhooks(r.Context(), int64(id))
+		if err != nil {
+			writeError(w, r, http.StatusInternalServerError, codeInternal, "Ошибка при обращении к бд")
+			log.Info("Вебхуки не отданы: ошибка при обращении к бд", sl.Err(err))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(ResponseToWebhooks{Deliveries: deliveries}); err != nil {
+			log.Info("Вебхуки не отданы: ошибка при записи", sl.Err(err))
+		}
+	}
+}
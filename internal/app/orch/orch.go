@@ -1,9 +1,11 @@
 package orchApp
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
 	"net"
+	"time"
 
 	"github.com/kms-qwe/DAEC/internal/grpc/orch"
 	"google.golang.org/grpc"
@@ -13,6 +15,9 @@ type App struct {
 	log        *slog.Logger
 	gRPCServer *grpc.Server
 	port       int
+	taskPuller *orch.TaskPuller
+	// stopEval останавливает выдачу задач в цикле Eval
+	stopEval context.CancelFunc
 }
 
 func New(
@@ -23,12 +28,15 @@ func New(
 	gRPCServer := grpc.NewServer()
 	orch.Register(gRPCServer, taskPuller)
 
-	go taskPuller.Eval()
+	ctx, cancel := context.WithCancel(context.Background())
+	go taskPuller.Eval(ctx)
 
 	return &App{
 		log:        log,
 		gRPCServer: gRPCServer,
 		port:       port,
+		taskPuller: taskPuller,
+		stopEval:   cancel,
 	}
 }
 
@@ -56,18 +64,34 @@ func (a *App) Run() error {
 		slog.String("addr", l.Addr().String()),
 	)
 
-	if err := a.gRPCServer.Serve(l); err != nil {
+	if err := a.gRPCServer.Serve(l); err != nil && err != grpc.ErrServerStopped {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
 	return nil
 }
 
+// Stop - прекращает выдачу задач, ждет результатов уже выданных (не дольше
+// DrainTimeout), затем останавливает gRPC сервер. Незавершенные вызовы
+// сервер ждет не дольше DrainTimeout, после чего закрывает соединения
 func (a *App) Stop() {
 	const op = "grpcapp.Stop"
-
-	a.log.With(slog.String("op", op)).
-		Info("stopping gRPC server", slog.Int("port", a.port))
-
-	a.gRPCServer.GracefulStop()
+	log := a.log.With(slog.String("op", op))
+
+	log.Info("stopping task dispatch")
+	a.stopEval()
+	<-a.taskPuller.Stopped
+
+	log.Info("stopping gRPC server", slog.Int("port", a.port))
+	done := make(chan struct{})
+	go func() {
+		a.gRPCServer.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(a.taskPuller.DrainTimeout):
+		log.Info("gRPC server did not stop in time, closing connections")
+		a.gRPCServer.Stop()
+	}
 }
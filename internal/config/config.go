@@ -12,13 +12,64 @@ type Config struct {
 	Env            string        `yaml:"env" env-default:"local"`
 	StoragePath    string        `yaml:"storage_path" env-required:"true"`
 	TokenTTL       time.Duration `yaml:"tokenTTL" env-required:"true"`
+	RefreshTTL     time.Duration `yaml:"refreshTokenTTL" env-default:"720h"`
+	JWT            JWTConfig     `yaml:"jwt"`
 	GRPC           GRPCConfig    `yaml:"grpc_server" env-required:"true"`
 	HTTP           HTTPConfig    `yaml:"http_server" env-required:"true"`
 	Addition       time.Duration `yaml:"time_addition_ms" env-required:"true"`
 	Subtraction    time.Duration `yaml:"time_subtraction_ms" env-required:"true"`
 	Multiplication time.Duration `yaml:"time_multiplication_ms" env-required:"true"`
 	Division       time.Duration `yaml:"time_division_ms" env-required:"true"`
-	ComputingPower int           `yaml:"computing_power" env-required:"true"`
+	IntDivision    time.Duration `yaml:"time_int_division_ms" env-default:"1000ms"`
+	Modulo         time.Duration `yaml:"time_modulo_ms" env-default:"1000ms"`
+	Exponentiation time.Duration `yaml:"time_exponentiation_ms" env-default:"1000ms"`
+	// Function - время вызова встроенной функции, если для нее нет своего в Functions
+	Function       time.Duration            `yaml:"time_function_ms" env-default:"1000ms"`
+	Functions      map[string]time.Duration `yaml:"time_functions_ms"`
+	ComputingPower int                      `yaml:"computing_power" env-required:"true"`
+	MaxInFlight    int                      `yaml:"max_in_flight" env-default:"10"`
+	PollInterval   time.Duration            `yaml:"poll_interval" env-default:"1s"`
+	LeaseGrace     time.Duration            `yaml:"lease_grace" env-default:"5s"`
+	AgentTimeout   time.Duration            `yaml:"agent_timeout" env-default:"15s"`
+	// WatchInterval - как часто auth проверяет бд для ожидания и потока событий выражений
+	WatchInterval time.Duration `yaml:"watch_interval" env-default:"500ms"`
+	// ShutdownTimeout - сколько ждать завершения текущей работы при остановке
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" env-default:"10s"`
+	Agent           AgentConfig   `yaml:"agent"`
+	Webhook         WebhookConfig `yaml:"webhook"`
+}
+
+// WebhookConfig - доставка вебхуков оркестратором; без secret вебхуки не отправляются.
+// Попытка n повторяется через backoff * 2^(n-1), но не позже чем через max_backoff
+type WebhookConfig struct {
+	Secret       string        `yaml:"secret" env:"WEBHOOK_SECRET" json:"-"`
+	Timeout      time.Duration `yaml:"timeout" env-default:"5s"`
+	MaxAttempts  int           `yaml:"max_attempts" env-default:"8"`
+	Backoff      time.Duration `yaml:"backoff" env-default:"1s"`
+	MaxBackoff   time.Duration `yaml:"max_backoff" env-default:"10m"`
+	PollInterval time.Duration `yaml:"poll_interval" env-default:"1s"`
+}
+
+// AgentConfig - настройки агента; пустой id заменяется на hostname-pid
+type AgentConfig struct {
+	ID                string        `yaml:"id" env:"AGENT_ID"`
+	OrchAddress       string        `yaml:"orch_address" env:"ORCH_ADDRESS" env-default:"localhost:8000"`
+	HeartbeatInterval time.Duration `yaml:"heartbeat_interval" env-default:"5s"`
+}
+
+// JWTConfig - ключи токенов: signing_kid - активный ключ подписи,
+// остальные ключи из keys только проверяют ранее выданные токены
+type JWTConfig struct {
+	SigningKID string   `yaml:"signing_kid" env:"JWT_SIGNING_KID"`
+	Keys       []JWTKey `yaml:"keys"`
+}
+
+type JWTKey struct {
+	KID            string `yaml:"kid"`
+	Alg            string `yaml:"alg"`
+	Secret         string `yaml:"secret" json:"-"`
+	PrivateKeyFile string `yaml:"private_key_file"`
+	PublicKeyFile  string `yaml:"public_key_file"`
 }
 
 type GRPCConfig struct {
@@ -31,6 +82,14 @@ type HTTPConfig struct {
 	Timeout time.Duration `yaml:"timeout"`
 }
 
+// FunctionTime - время вызова встроенной функции name
+func (c *Config) FunctionTime(name string) time.Duration {
+	if d, ok := c.Functions[name]; ok {
+		return d
+	}
+	return c.Function
+}
+
 func MastLoad() *Config {
 	path := fetchConfigPath()
 	if path == "" {
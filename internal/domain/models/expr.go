@@ -0,0 +1,7 @@
+package models
+
+// Expr - выражение, ожидающее вычисления оркестратором
+type Expr struct {
+	ID         int64
+	PolishExpr string
+}
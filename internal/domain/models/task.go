@@ -0,0 +1,29 @@
+package models
+
+// Статусы задачи
+const (
+	TaskPending = "pending"
+	TaskLeased  = "leased"
+	TaskDone    = "done"
+	TaskError   = "error"
+)
+
+// Task - операция выражения, как она хранится в бд. По строкам задач
+// оркестратор после перезапуска восстанавливает граф выражения.
+// ParentID = 0 у корня; аргумент равен nil, пока он ждет результата
+// дочерней задачи (дочерняя задача - та, у которой ParentID и ArgIdx указывают сюда)
+type Task struct {
+	ExprID int64
+	NodeID int64
+	Op     string
+	// Pos - позиция вызова функции в исходном выражении, -1 у операторов
+	Pos        int
+	ParentID   int64
+	ArgIdx     int
+	Args       []*float64
+	Status     string
+	AgentID    string
+	Attempt    int
+	LeaseToken string
+	Result     *float64
+}
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// RefreshToken - сохраненный refresh-токен; в бд хранится только хэш токена.
+// Токены, выпущенные друг из друга при обновлении, образуют семейство FamilyID
+type RefreshToken struct {
+	Hash      string
+	UserID    int64
+	Login     string
+	FamilyID  string
+	ExpiresAt time.Time
+	Used      bool
+	Revoked   bool
+}
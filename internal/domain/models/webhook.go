@@ -0,0 +1,51 @@
+package models
+
+import "time"
+
+// Статусы доставки вебхука
+const (
+	WebhookPending   = "pending"
+	WebhookDelivered = "delivered"
+	WebhookFailed    = "failed"
+)
+
+// События вебхуков
+const (
+	EventExprDone      = "expression.done"
+	EventExprFailed    = "expression.failed"
+	EventExprCancelled = "expression.cancelled"
+)
+
+// Webhook - уведомление из outbox. Запись создается в одной транзакции
+// с завершением выражения, поэтому уведомление не теряется при падении процесса
+type Webhook struct {
+	ID            int64
+	ExprID        int64
+	URL           string
+	Event         string
+	Payload       []byte
+	Status        string
+	Attempts      int
+	NextAttemptAt time.Time
+	CreatedAt     time.Time
+}
+
+// WebhookAttempt - одна попытка доставки вебхука; StatusCode = 0, если ответа не было
+type WebhookAttempt struct {
+	WebhookID  int64
+	Attempt    int
+	At         time.Time
+	StatusCode int
+	Error      string
+	Duration   time.Duration
+}
+
+// ExprEvent - тело вебхука о завершении выражения
+type ExprEvent struct {
+	Event      string    `json:"event"`
+	ID         int64     `json:"id"`
+	Status     string    `json:"status"`
+	Result     float64   `json:"result"`
+	Reason     string    `json:"reason,omitempty"`
+	FinishedAt time.Time `json:"finished_at"`
+}
@@ -0,0 +1,108 @@
+package expr
+
+// Node - узел синтаксического дерева выражения
+type Node interface {
+	// Pos - смещение (в байтах) начала узла в исходной строке
+	Pos() int
+	node()
+}
+
+// Number - числовой литерал или уже вычисленное значение
+type Number struct {
+	At    int
+	Value float64
+	// Lit - исходная запись литерала, пустая для вычисленных значений
+	Lit string
+}
+
+// Unary - унарная операция (+x, -x)
+type Unary struct {
+	At int
+	Op string
+	X  Node
+}
+
+// Binary - бинарная операция (x + y, x * y, ...)
+type Binary struct {
+	// At - позиция оператора
+	At int
+	Op string
+	X  Node
+	Y  Node
+}
+
+// Group - выражение в скобках
+type Group struct {
+	Lparen int
+	Rparen int
+	X      Node
+}
+
+// Var - переменная, значение задается привязкой (см. Bind)
+type Var struct {
+	At   int
+	Name string
+}
+
+// Call - вызов встроенной функции (sqrt(x), max(x, y, ...))
+type Call struct {
+	// At - позиция имени функции
+	At     int
+	Name   string
+	Args   []Node
+	Rparen int
+}
+
+func (n *Number) Pos() int { return n.At }
+func (n *Unary) Pos() int  { return n.At }
+func (n *Binary) Pos() int { return n.X.Pos() }
+func (n *Group) Pos() int  { return n.Lparen }
+func (n *Call) Pos() int   { return n.At }
+func (n *Var) Pos() int    { return n.At }
+
+func (*Number) node() {}
+func (*Unary) node()  {}
+func (*Binary) node() {}
+func (*Group) node()  {}
+func (*Call) node()   {}
+func (*Var) node()    {}
+
+// Walk - обход дерева в глубину; fn вызывается до обхода потомков,
+// если fn возвращает false, потомки узла не обходятся
+func Walk(n Node, fn func(Node) bool) {
+	if n == nil || !fn(n) {
+		return
+	}
+	switch n := n.(type) {
+	case *Unary:
+		Walk(n.X, fn)
+	case *Binary:
+		Walk(n.X, fn)
+		Walk(n.Y, fn)
+	case *Group:
+		Walk(n.X, fn)
+	case *Call:
+		for _, a := range n.Args {
+			Walk(a, fn)
+		}
+	}
+}
+
+// Rewrite - обход дерева снизу вверх с заменой узлов:
+// fn получает узел с уже переписанными потомками и возвращает замену
+func Rewrite(n Node, fn func(Node) Node) Node {
+	switch n := n.(type) {
+	case *Unary:
+		n.X = Rewrite(n.X, fn)
+	case *Binary:
+		n.X = Rewrite(n.X, fn)
+		n.Y = Rewrite(n.Y, fn)
+	case *Group:
+		n.X = Rewrite(n.X, fn)
+	case *Call:
+		for i, a := range n.Args {
+			n.Args[i] = Rewrite(a, fn)
+		}
+	}
+	return fn(n)
+}
@@ -0,0 +1,70 @@
+package expr
+
+import "sort"
+
+// Vars - имена переменных выражения по алфавиту, без повторов
+func Vars(n Node) []string {
+	seen := map[string]bool{}
+	var names []string
+	Walk(n, func(n Node) bool {
+		if v, ok := n.(*Var); ok && !seen[v.Name] {
+			seen[v.Name] = true
+			names = append(names, v.Name)
+		}
+		return true
+	})
+	sort.Strings(names)
+	return names
+}
+
+// Unbound - ошибки для переменных без значения в bindings, по одной
+// на переменную, с позицией ее первого вхождения
+func Unbound(n Node, bindings map[string]float64) []*Error {
+	seen := map[string]bool{}
+	var errs []*Error
+	Walk(n, func(n Node) bool {
+		v, ok := n.(*Var)
+		if !ok || seen[v.Name] {
+			return true
+		}
+		seen[v.Name] = true
+		if _, ok := bindings[v.Name]; !ok {
+			errs = append(errs, errorf(v.At, "unbound variable %q", v.Name))
+		}
+		return true
+	})
+	return errs
+}
+
+// Bind - подставляет значения переменных из bindings. Ошибка - с позицией
+// первой переменной без значения; после подстановки, как и при разборе,
+// проверяются деление на ноль и область определения функций от чисел.
+// Дерево n изменяется
+func Bind(n Node, bindings map[string]float64) (Node, error) {
+	var bindErr *Error
+	n = Rewrite(n, func(n Node) Node {
+		if bindErr != nil {
+			return n
+		}
+		switch n := n.(type) {
+		case *Var:
+			v, ok := bindings[n.Name]
+			if !ok {
+				bindErr = errorf(n.At, "unbound variable %q", n.Name)
+				return n
+			}
+			return &Number{At: n.At, Value: v}
+		case *Binary:
+			if isDivision(n.Op) && isZeroLiteral(n.Y) {
+				bindErr = errorf(n.Y.Pos(), "division by zero")
+			}
+		case *Call:
+			bindErr = checkDomain(n)
+		}
+		return n
+	})
+	if bindErr != nil {
+		return nil, bindErr
+	}
+	return n, nil
+}
@@ -0,0 +1,94 @@
+package expr
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestVars(t *testing.T) {
+	tests := []struct {
+		src  string
+		want []string
+	}{
+		{src: "1 + 2", want: nil},
+		{src: "y * x + x", want: []string{"x", "y"}},
+		{src: "sqrt(rate) ^ -n", want: []string{"n", "rate"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			n, err := Parse(tt.src)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if got := Vars(n); !slices.Equal(got, tt.want) {
+				t.Errorf("Vars = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnbound(t *testing.T) {
+	n, err := Parse("a + b * a - c")
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	errs := Unbound(n, map[string]float64{"b": 1})
+	var got []string
+	for _, e := range errs {
+		got = append(got, e.Error())
+	}
+	want := []string{`pos 0: unbound variable "a"`, `pos 12: unbound variable "c"`}
+	if !slices.Equal(got, want) {
+		t.Errorf("Unbound = %q, want %q", got, want)
+	}
+}
+
+func TestBind(t *testing.T) {
+	tests := []struct {
+		src      string
+		bindings map[string]float64
+		want     string
+		pos      int
+		msg      string
+	}{
+		{src: "x / y", bindings: map[string]float64{"x": 1, "y": 2}, want: "1 2 /"},
+		{src: "-x^2", bindings: map[string]float64{"x": 3}, want: "3 2 ^ ~"},
+		{src: "x * 2", bindings: map[string]float64{"x": -1.5, "unused": 1}, want: "-1.5 2 *"},
+		// аргумент вычисляется агентом, вызов сохраняет позицию для ошибки
+		{src: "sqrt(x - 5)", bindings: map[string]float64{"x": 1}, want: "1 5 - sqrt@1@0"},
+		{src: "x + y", bindings: map[string]float64{"x": 1}, pos: 4, msg: `unbound variable "y"`},
+		{src: "1 / x", bindings: map[string]float64{"x": 0}, pos: 4, msg: "division by zero"},
+		{src: "7 % n", bindings: map[string]float64{"n": 0}, pos: 4, msg: "division by zero"},
+		{src: "sqrt(x)", bindings: map[string]float64{"x": -4}, pos: 5, msg: "sqrt: argument must be non-negative"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			n, err := Parse(tt.src)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			n, err = Bind(n, tt.bindings)
+			if tt.msg == "" {
+				if err != nil {
+					t.Fatalf("Bind: %v", err)
+				}
+				got, err := Postfix(n)
+				if err != nil {
+					t.Fatalf("Postfix: %v", err)
+				}
+				if got != tt.want {
+					t.Errorf("Postfix = %q, want %q", got, tt.want)
+				}
+				return
+			}
+			var perr *Error
+			if !errors.As(err, &perr) {
+				t.Fatalf("Bind error = %v, want *Error", err)
+			}
+			if perr.Pos != tt.pos || perr.Msg != tt.msg {
+				t.Errorf("Bind error = %d %q, want %d %q", perr.Pos, perr.Msg, tt.pos, tt.msg)
+			}
+		})
+	}
+}
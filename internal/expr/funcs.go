@@ -0,0 +1,150 @@
+package expr
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Func - встроенная функция выражений
+type Func struct {
+	// MinArgs, MaxArgs - допустимое число аргументов, MaxArgs < 0 - без ограничения
+	MinArgs int
+	MaxArgs int
+	// Call - вычисляет функцию, число аргументов уже проверено
+	Call func(args []float64) (float64, error)
+}
+
+// DomainError - аргумент функции вне ее области определения
+type DomainError struct {
+	// Arg - номер аргумента (с 0)
+	Arg int
+	Msg string
+}
+
+func (e *DomainError) Error() string {
+	return e.Msg
+}
+
+// Funcs - встроенные функции; каждый вызов вычисляется агентом отдельной задачей
+var Funcs = map[string]Func{
+	"sqrt": unary(func(x float64) (float64, error) {
+		if x < 0 {
+			return 0, &DomainError{Msg: "argument must be non-negative"}
+		}
+		return math.Sqrt(x), nil
+	}),
+	"abs": unary(total(math.Abs)),
+	"min": {MinArgs: 1, MaxArgs: -1, Call: func(args []float64) (float64, error) {
+		res := args[0]
+		for _, v := range args[1:] {
+			res = math.Min(res, v)
+		}
+		return res, nil
+	}},
+	"max": {MinArgs: 1, MaxArgs: -1, Call: func(args []float64) (float64, error) {
+		res := args[0]
+		for _, v := range args[1:] {
+			res = math.Max(res, v)
+		}
+		return res, nil
+	}},
+	"sin": unary(total(math.Sin)),
+	"cos": unary(total(math.Cos)),
+	"tan": unary(total(math.Tan)),
+	"log": unary(func(x float64) (float64, error) {
+		if x <= 0 {
+			return 0, &DomainError{Msg: "argument must be positive"}
+		}
+		return math.Log(x), nil
+	}),
+	"exp":   unary(total(math.Exp)),
+	"floor": unary(total(math.Floor)),
+	"ceil":  unary(total(math.Ceil)),
+	"round": unary(total(math.Round)),
+}
+
+func unary(fn func(float64) (float64, error)) Func {
+	return Func{MinArgs: 1, MaxArgs: 1, Call: func(args []float64) (float64, error) {
+		return fn(args[0])
+	}}
+}
+
+func total(fn func(float64) float64) func(float64) (float64, error) {
+	return func(x float64) (float64, error) {
+		return fn(x), nil
+	}
+}
+
+// FuncNames - имена встроенных функций по алфавиту
+func FuncNames() []string {
+	names := make([]string, 0, len(Funcs))
+	for name := range Funcs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// CheckArity - ошибка, если функция name не принимает argc аргументов
+func CheckArity(name string, argc int) error {
+	f, ok := Funcs[name]
+	if !ok {
+		return fmt.Errorf("unknown function %q", name)
+	}
+	switch {
+	case f.MaxArgs < 0 && argc < f.MinArgs:
+		return fmt.Errorf("%s expects at least %s, got %d", name, arguments(f.MinArgs), argc)
+	case f.MaxArgs >= 0 && (argc < f.MinArgs || argc > f.MaxArgs):
+		if f.MinArgs == f.MaxArgs {
+			return fmt.Errorf("%s expects %s, got %d", name, arguments(f.MinArgs), argc)
+		}
+		return fmt.Errorf("%s expects from %d to %d arguments, got %d", name, f.MinArgs, f.MaxArgs, argc)
+	}
+	return nil
+}
+
+func arguments(n int) string {
+	if n == 1 {
+		return "1 argument"
+	}
+	return strconv.Itoa(n) + " arguments"
+}
+
+// CallToken - лексема вызова функции в обратной польской записи:
+// имя@число_аргументов@позиция, позиция - начало вызова в исходном выражении
+func CallToken(name string, argc, pos int) string {
+	return name + "@" + strconv.Itoa(argc) + "@" + strconv.Itoa(pos)
+}
+
+// parseCallToken - имя, число аргументов и позиция из лексемы CallToken;
+// в записях без позиции (имя@число_аргументов) позиция -1
+func parseCallToken(tok string) (name string, argc, pos int, ok bool) {
+	name, rest, ok := strings.Cut(tok, "@")
+	if !ok {
+		return "", 0, 0, false
+	}
+	n, p, hasPos := strings.Cut(rest, "@")
+	argc, err := strconv.Atoi(n)
+	if err != nil || argc < 0 {
+		return "", 0, 0, false
+	}
+	pos = -1
+	if hasPos {
+		if pos, err = strconv.Atoi(p); err != nil || pos < 0 {
+			return "", 0, 0, false
+		}
+	}
+	return name, argc, pos, true
+}
+
+// FormatCall - запись вызова с вычисленными аргументами для сообщений об ошибках
+func FormatCall(name string, args []float64) string {
+	parts := make([]string, len(args))
+	for i, v := range args {
+		parts[i] = strconv.FormatFloat(v, 'g', -1, 64)
+	}
+	return name + "(" + strings.Join(parts, ", ") + ")"
+}
@@ -0,0 +1,169 @@
+package expr
+
+import (
+	"fmt"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Kind - тип лексемы
+type Kind int
+
+const (
+	EOF Kind = iota
+	NUM
+	OP
+	LPAREN
+	RPAREN
+	IDENT
+	COMMA
+)
+
+func (k Kind) String() string {
+	switch k {
+	case EOF:
+		return "end of expression"
+	case NUM:
+		return "number"
+	case OP:
+		return "operator"
+	case LPAREN:
+		return "'('"
+	case RPAREN:
+		return "')'"
+	case IDENT:
+		return "identifier"
+	case COMMA:
+		return "','"
+	}
+	return "unknown"
+}
+
+// Token - лексема с позицией в исходной строке
+type Token struct {
+	Kind Kind
+	Lit  string
+	Pos  int
+}
+
+// Error - ошибка разбора выражения с позицией в исходной строке
+type Error struct {
+	Pos int
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("pos %d: %s", e.Pos, e.Msg)
+}
+
+func errorf(pos int, format string, args ...any) *Error {
+	return &Error{Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+// Lexer - разбивает строку выражения на лексемы
+type Lexer struct {
+	src string
+	pos int
+}
+
+func NewLexer(src string) *Lexer {
+	return &Lexer{src: src}
+}
+
+// Next - возвращает следующую лексему, по достижении конца строки - EOF
+func (l *Lexer) Next() (Token, error) {
+	for l.pos < len(l.src) {
+		r, size := utf8.DecodeRuneInString(l.src[l.pos:])
+		if !unicode.IsSpace(r) {
+			break
+		}
+		l.pos += size
+	}
+	if l.pos >= len(l.src) {
+		return Token{Kind: EOF, Pos: l.pos}, nil
+	}
+
+	start := l.pos
+	c := l.src[l.pos]
+	switch {
+	case isDigit(c), c == '.':
+		return l.number()
+	case c == '/' && l.next() == '/':
+		l.pos += 2
+		return Token{Kind: OP, Lit: "//", Pos: start}, nil
+	case c == '+', c == '-', c == '*', c == '/', c == '%', c == '^':
+		l.pos++
+		return Token{Kind: OP, Lit: string(c), Pos: start}, nil
+	case isLetter(c):
+		for l.pos < len(l.src) && (isLetter(l.src[l.pos]) || isDigit(l.src[l.pos])) {
+			l.pos++
+		}
+		return Token{Kind: IDENT, Lit: l.src[start:l.pos], Pos: start}, nil
+	case c == ',':
+		l.pos++
+		return Token{Kind: COMMA, Lit: ",", Pos: start}, nil
+	case c == '(':
+		l.pos++
+		return Token{Kind: LPAREN, Lit: "(", Pos: start}, nil
+	case c == ')':
+		l.pos++
+		return Token{Kind: RPAREN, Lit: ")", Pos: start}, nil
+	}
+
+	r, _ := utf8.DecodeRuneInString(l.src[l.pos:])
+	return Token{}, errorf(start, "unexpected character %q", r)
+}
+
+// number - десятичный литерал: 12, 1.5, .5, 2., 3e-4, 1.2E+10
+func (l *Lexer) number() (Token, error) {
+	start := l.pos
+	digits := l.digits()
+	if l.peek() == '.' {
+		l.pos++
+		digits += l.digits()
+	}
+	if digits == 0 {
+		return Token{}, errorf(start, "malformed number %q", l.src[start:l.pos])
+	}
+	if c := l.peek(); c == 'e' || c == 'E' {
+		l.pos++
+		if c := l.peek(); c == '+' || c == '-' {
+			l.pos++
+		}
+		if l.digits() == 0 {
+			return Token{}, errorf(start, "malformed exponent in %q", l.src[start:l.pos])
+		}
+	}
+	return Token{Kind: NUM, Lit: l.src[start:l.pos], Pos: start}, nil
+}
+
+func (l *Lexer) digits() int {
+	start := l.pos
+	for l.pos < len(l.src) && isDigit(l.src[l.pos]) {
+		l.pos++
+	}
+	return l.pos - start
+}
+
+func (l *Lexer) peek() byte {
+	if l.pos < len(l.src) {
+		return l.src[l.pos]
+	}
+	return 0
+}
+
+// next - байт после текущего
+func (l *Lexer) next() byte {
+	if l.pos+1 < len(l.src) {
+		return l.src[l.pos+1]
+	}
+	return 0
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+func isLetter(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || c == '_'
+}
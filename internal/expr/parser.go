@@ -0,0 +1,299 @@
+package expr
+
+import (
+	"errors"
+	"strconv"
+)
+
+// Parse - разбирает инфиксную запись выражения в синтаксическое дерево.
+//
+// Грамматика:
+//
+//	expr     = term { ("+" | "-") term }
+//	term     = unary { ("*" | "/" | "//" | "%") unary }
+//	unary    = [ "+" | "-" ] power
+//	power    = primary [ "^" unary ]
+//	primary  = number | call | ident | "(" expr ")"
+//	call     = ident "(" [ expr { "," expr } ] ")"
+//	number   = digits [ "." [ digits ] ] [ exponent ] | "." digits [ exponent ]
+//	exponent = ("e" | "E") [ "+" | "-" ] digits
+//
+// "^" правоассоциативен и связывает сильнее унарного минуса:
+// 2^3^2 = 2^(3^2), -2^2 = -(2^2), 2^-1 = 2^(-1).
+// "//" - деление с округлением вниз, "%" - остаток от такого деления.
+// ident перед "(" - имя функции из Funcs; число аргументов проверяется при
+// разборе, как и область определения, если все аргументы - числа.
+// Остальные ident - переменные, их значения подставляет Bind.
+func Parse(src string) (Node, error) {
+	p := &parser{lex: NewLexer(src)}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if p.tok.Kind == EOF {
+		return nil, errorf(p.tok.Pos, "empty expression")
+	}
+
+	n, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.Kind != EOF {
+		return nil, p.unexpected()
+	}
+	return n, nil
+}
+
+type parser struct {
+	lex *Lexer
+	tok Token
+}
+
+func (p *parser) advance() error {
+	tok, err := p.lex.Next()
+	if err != nil {
+		return err
+	}
+	p.tok = tok
+	return nil
+}
+
+func (p *parser) unexpected() *Error {
+	if p.tok.Kind == EOF {
+		return errorf(p.tok.Pos, "unexpected end of expression")
+	}
+	if p.tok.Kind == LPAREN || p.tok.Kind == RPAREN || p.tok.Kind == COMMA {
+		return errorf(p.tok.Pos, "unexpected %s", p.tok.Kind)
+	}
+	return errorf(p.tok.Pos, "unexpected %s %q", p.tok.Kind, p.tok.Lit)
+}
+
+func (p *parser) isOp(ops ...string) bool {
+	if p.tok.Kind != OP {
+		return false
+	}
+	for _, op := range ops {
+		if p.tok.Lit == op {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *parser) parseExpr() (Node, error) {
+	x, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("+", "-") {
+		op := p.tok
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		y, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		x = &Binary{At: op.Pos, Op: op.Lit, X: x, Y: y}
+	}
+	return x, nil
+}
+
+func (p *parser) parseTerm() (Node, error) {
+	x, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("*", "/", "//", "%") {
+		op := p.tok
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		y, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if isDivision(op.Lit) && isZeroLiteral(y) {
+			return nil, errorf(y.Pos(), "division by zero")
+		}
+		x = &Binary{At: op.Pos, Op: op.Lit, X: x, Y: y}
+	}
+	return x, nil
+}
+
+func (p *parser) parseUnary() (Node, error) {
+	if p.isOp("+", "-") {
+		op := p.tok
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		x, err := p.parsePower()
+		if err != nil {
+			return nil, err
+		}
+		return &Unary{At: op.Pos, Op: op.Lit, X: x}, nil
+	}
+	return p.parsePower()
+}
+
+func (p *parser) parsePower() (Node, error) {
+	x, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	if !p.isOp("^") {
+		return x, nil
+	}
+	op := p.tok
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	y, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	return &Binary{At: op.Pos, Op: op.Lit, X: x, Y: y}, nil
+}
+
+func (p *parser) parsePrimary() (Node, error) {
+	switch p.tok.Kind {
+	case NUM:
+		tok := p.tok
+		v, err := strconv.ParseFloat(tok.Lit, 64)
+		if err != nil {
+			if errors.Is(err, strconv.ErrRange) {
+				return nil, errorf(tok.Pos, "number %q is out of range", tok.Lit)
+			}
+			return nil, errorf(tok.Pos, "invalid number %q", tok.Lit)
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		return &Number{At: tok.Pos, Value: v, Lit: tok.Lit}, nil
+	case LPAREN:
+		lparen := p.tok.Pos
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		x, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if p.tok.Kind != RPAREN {
+			if p.tok.Kind == EOF {
+				return nil, errorf(lparen, "unclosed '('")
+			}
+			return nil, p.unexpected()
+		}
+		rparen := p.tok.Pos
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		return &Group{Lparen: lparen, Rparen: rparen, X: x}, nil
+	case IDENT:
+		return p.parseIdent()
+	}
+	return nil, p.unexpected()
+}
+
+// parseIdent - вызов функции или переменная
+func (p *parser) parseIdent() (Node, error) {
+	name := p.tok
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	_, isFunc := Funcs[name.Lit]
+	if p.tok.Kind != LPAREN {
+		if isFunc {
+			return nil, errorf(p.tok.Pos, "expected '(' after %s", name.Lit)
+		}
+		return &Var{At: name.Pos, Name: name.Lit}, nil
+	}
+	if !isFunc {
+		return nil, errorf(name.Pos, "unknown function %q", name.Lit)
+	}
+	lparen := p.tok.Pos
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+
+	call := &Call{At: name.Pos, Name: name.Lit}
+	if p.tok.Kind != RPAREN {
+		for {
+			x, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			call.Args = append(call.Args, x)
+			if p.tok.Kind != COMMA {
+				break
+			}
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if p.tok.Kind != RPAREN {
+		if p.tok.Kind == EOF {
+			return nil, errorf(lparen, "unclosed '('")
+		}
+		return nil, p.unexpected()
+	}
+	call.Rparen = p.tok.Pos
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+
+	if err := CheckArity(call.Name, len(call.Args)); err != nil {
+		return nil, errorf(call.At, "%s", err)
+	}
+	if err := checkDomain(call); err != nil {
+		return nil, err
+	}
+	return call, nil
+}
+
+// checkDomain - вычисляет функцию, если все ее аргументы - числа,
+// и возвращает ошибку с позицией аргумента вне области определения
+func checkDomain(call *Call) *Error {
+	args := make([]float64, len(call.Args))
+	for i, a := range call.Args {
+		v, ok := literalValue(a)
+		if !ok {
+			return nil
+		}
+		args[i] = v
+	}
+	_, err := Funcs[call.Name].Call(args)
+	var domainErr *DomainError
+	if errors.As(err, &domainErr) {
+		return errorf(call.Args[domainErr.Arg].Pos(), "%s: %s", call.Name, domainErr.Msg)
+	}
+	return nil
+}
+
+// isDivision - операция, для которой нулевой делитель - ошибка
+func isDivision(op string) bool {
+	return op == "/" || op == "//" || op == "%"
+}
+
+// isZeroLiteral - является ли узел литералом нуля (с учетом знака)
+func isZeroLiteral(n Node) bool {
+	v, ok := literalValue(n)
+	return ok && v == 0
+}
+
+// literalValue - значение узла, если он литерал числа (с учетом знака)
+func literalValue(n Node) (float64, bool) {
+	sign := 1.0
+	if u, ok := n.(*Unary); ok {
+		if u.Op == "-" {
+			sign = -1
+		}
+		n = u.X
+	}
+	num, ok := n.(*Number)
+	if !ok {
+		return 0, false
+	}
+	return sign * num.Value, true
+}
@@ -0,0 +1,125 @@
+package expr
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParsePrecedence(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{src: "1 + 2 * 3", want: "1 2 3 * +"},
+		{src: "(1 + 2) * 3", want: "1 2 + 3 *"},
+		{src: "1 - 2 - 3", want: "1 2 - 3 -"},
+		{src: "8 / 4 / 2", want: "8 4 / 2 /"},
+		{src: "-(1 + 2)", want: "1 2 + ~"},
+		{src: "-3 * 2", want: "-3 2 *"},
+		{src: "2 * -3", want: "2 -3 *"},
+		{src: "+5", want: "5"},
+		{src: "sqrt(16) - 1", want: "16 sqrt@1@0 1 -"},
+		{src: "2 * max(1, 2 + 3, 4)", want: "2 1 2 3 + 4 max@3@4 *"},
+		{src: "((7))", want: "7"},
+		{src: "1.5 * 2", want: "1.5 2 *"},
+		{src: "3e-4 + 1", want: "3e-4 1 +"},
+		{src: ".5 + 2.", want: ".5 2. +"},
+		{src: "-1E+3", want: "-1000"},
+		{src: "2^3^2", want: "2 3 2 ^ ^"},
+		{src: "-2^2", want: "2 2 ^ ~"},
+		{src: "2^-1", want: "2 -1 ^"},
+		{src: "2 * 3^2", want: "2 3 2 ^ *"},
+		{src: "-7 // 2", want: "-7 2 //"},
+		{src: "7 % -3", want: "7 -3 %"},
+		{src: "-7 % -3 // 2", want: "-7 -3 % 2 //"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			n, err := Parse(tt.src)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			got, err := Postfix(n)
+			if err != nil {
+				t.Fatalf("Postfix: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Postfix = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseNumber(t *testing.T) {
+	tests := []struct {
+		src  string
+		want float64
+	}{
+		{src: "42", want: 42},
+		{src: "1.5", want: 1.5},
+		{src: ".5", want: 0.5},
+		{src: "2.", want: 2},
+		{src: "3e-4", want: 3e-4},
+		{src: "1E+3", want: 1000},
+		{src: "2.5e2", want: 250},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			n, err := Parse(tt.src)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			num, ok := n.(*Number)
+			if !ok {
+				t.Fatalf("Parse = %T, want *Number", n)
+			}
+			if num.Value != tt.want {
+				t.Errorf("Value = %v, want %v", num.Value, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrorPos(t *testing.T) {
+	tests := []struct {
+		src string
+		pos int
+		msg string
+	}{
+		{src: "", pos: 0, msg: "empty expression"},
+		{src: "1 +", pos: 3, msg: "unexpected end of expression"},
+		{src: "1 + * 2", pos: 4, msg: `unexpected operator "*"`},
+		{src: "(1 + 2", pos: 0, msg: "unclosed '('"},
+		{src: "1 + 2)", pos: 5, msg: "unexpected ')'"},
+		{src: "1 + ()", pos: 5, msg: "unexpected ')'"},
+		{src: "1 $ 2", pos: 2, msg: "unexpected character '$'"},
+		{src: "2 3", pos: 2, msg: `unexpected number "3"`},
+		{src: "1 / 0", pos: 4, msg: "division by zero"},
+		{src: "1 / 0.0", pos: 4, msg: "division by zero"},
+		{src: "1 // 0", pos: 5, msg: "division by zero"},
+		{src: "-7 % 0.0", pos: 5, msg: "division by zero"},
+		{src: "2 ^ ^ 3", pos: 4, msg: `unexpected operator "^"`},
+		{src: "1,2", pos: 1, msg: "unexpected ','"},
+		{src: "foo(1)", pos: 0, msg: `unknown function "foo"`},
+		{src: "sqrt 2", pos: 5, msg: "expected '(' after sqrt"},
+		{src: "sqrt(1, 2)", pos: 0, msg: "sqrt expects 1 argument, got 2"},
+		{src: "max()", pos: 0, msg: "max expects at least 1 argument, got 0"},
+		{src: "1 + sqrt(-4)", pos: 9, msg: "sqrt: argument must be non-negative"},
+		{src: "log(2, 0)", pos: 0, msg: "log expects 1 argument, got 2"},
+		{src: "1..2", pos: 2, msg: `unexpected number ".2"`},
+		{src: "2 + 1e", pos: 4, msg: `malformed exponent in "1e"`},
+		{src: "1e+ * 2", pos: 0, msg: `malformed exponent in "1e+"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			_, err := Parse(tt.src)
+			var perr *Error
+			if !errors.As(err, &perr) {
+				t.Fatalf("Parse error = %v, want *Error", err)
+			}
+			if perr.Pos != tt.pos || perr.Msg != tt.msg {
+				t.Errorf("Parse error = %d %q, want %d %q", perr.Pos, perr.Msg, tt.pos, tt.msg)
+			}
+		})
+	}
+}
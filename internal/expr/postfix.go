@@ -0,0 +1,132 @@
+package expr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// OpNeg - лексема унарного минуса в обратной польской записи
+const OpNeg = "~"
+
+// Postfix - сериализует дерево в обратную польскую запись,
+// лексемы разделены одним пробелом. Унарный минус перед числом
+// записывается как отрицательное число, перед подвыражением - как OpNeg.
+// Вызов функции записывается после аргументов лексемой CallToken.
+// Ошибка - с позицией узла, который нельзя записать в обратной польской записи
+func Postfix(n Node) (string, error) {
+	var b strings.Builder
+	if err := writePostfix(&b, n); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+func writePostfix(b *strings.Builder, n Node) error {
+	switch n := n.(type) {
+	case *Number:
+		writeToken(b, FormatNumber(n))
+	case *Unary:
+		if n.Op == "+" {
+			return writePostfix(b, n.X)
+		}
+		if num, ok := n.X.(*Number); ok {
+			writeToken(b, FormatNumber(&Number{Value: -num.Value}))
+			return nil
+		}
+		if err := writePostfix(b, n.X); err != nil {
+			return err
+		}
+		writeToken(b, OpNeg)
+	case *Binary:
+		if err := writePostfix(b, n.X); err != nil {
+			return err
+		}
+		if err := writePostfix(b, n.Y); err != nil {
+			return err
+		}
+		writeToken(b, n.Op)
+	case *Group:
+		return writePostfix(b, n.X)
+	case *Call:
+		for _, a := range n.Args {
+			if err := writePostfix(b, a); err != nil {
+				return err
+			}
+		}
+		writeToken(b, CallToken(n.Name, len(n.Args), n.Pos()))
+	case *Var:
+		return errorf(n.At, "unbound variable %q", n.Name)
+	default:
+		return errorf(n.Pos(), "unexpected node %T", n)
+	}
+	return nil
+}
+
+func writeToken(b *strings.Builder, tok string) {
+	if b.Len() > 0 {
+		b.WriteByte(' ')
+	}
+	b.WriteString(tok)
+}
+
+// FormatNumber - запись числа, пригодная для ParsePostfix
+func FormatNumber(n *Number) string {
+	if n.Lit != "" {
+		return n.Lit
+	}
+	return strconv.FormatFloat(n.Value, 'g', -1, 64)
+}
+
+// ParsePostfix - восстанавливает дерево из обратной польской записи,
+// полученной через Postfix. Позиции узлов - порядковые номера лексем,
+// кроме вызовов функций: их позиция берется из лексемы CallToken.
+func ParsePostfix(s string) (Node, error) {
+	var stack []Node
+	for i, tok := range strings.Fields(s) {
+		switch tok {
+		case OpNeg:
+			if len(stack) < 1 {
+				return nil, fmt.Errorf("token %d: missing operand for %q", i, tok)
+			}
+			x := stack[len(stack)-1]
+			if num, ok := x.(*Number); ok {
+				stack[len(stack)-1] = &Number{At: num.At, Value: -num.Value}
+				continue
+			}
+			stack[len(stack)-1] = &Unary{At: i, Op: "-", X: x}
+		case "+", "-", "*", "/", "//", "%", "^":
+			if len(stack) < 2 {
+				return nil, fmt.Errorf("token %d: missing operand for %q", i, tok)
+			}
+			x, y := stack[len(stack)-2], stack[len(stack)-1]
+			stack = stack[:len(stack)-2]
+			stack = append(stack, &Binary{At: i, Op: tok, X: x, Y: y})
+		default:
+			if name, argc, pos, ok := parseCallToken(tok); ok {
+				if err := CheckArity(name, argc); err != nil {
+					return nil, fmt.Errorf("token %d: %w", i, err)
+				}
+				if len(stack) < argc {
+					return nil, fmt.Errorf("token %d: missing operand for %q", i, tok)
+				}
+				args := append([]Node(nil), stack[len(stack)-argc:]...)
+				stack = stack[:len(stack)-argc]
+				if pos < 0 {
+					pos = i
+				}
+				stack = append(stack, &Call{At: pos, Name: name, Args: args})
+				continue
+			}
+			v, err := strconv.ParseFloat(tok, 64)
+			if err != nil {
+				return nil, fmt.Errorf("token %d: invalid number %q", i, tok)
+			}
+			stack = append(stack, &Number{At: i, Value: v, Lit: tok})
+		}
+	}
+	if len(stack) != 1 {
+		return nil, fmt.Errorf("malformed postfix expression %q", s)
+	}
+	return stack[0], nil
+}
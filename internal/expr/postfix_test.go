@@ -0,0 +1,113 @@
+package expr
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPostfixRoundTrip(t *testing.T) {
+	// want пусто, если запись после восстановления не меняется;
+	// ParsePostfix сворачивает OpNeg перед числом в отрицательное число
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{src: "1 + 2 * 3"},
+		{src: "(1 + 2) * (3 - 4) / 5"},
+		{src: "-(1 + 2) * -3"},
+		{src: "-(-(2))", want: "2"},
+		{src: "1.5 * .25 - 3e-4 / 2.5E+2"},
+		{src: "1 + max(sqrt(4), -abs(2 - 3), 1)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			n, err := Parse(tt.src)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			rpn, err := Postfix(n)
+			if err != nil {
+				t.Fatalf("Postfix: %v", err)
+			}
+			back, err := ParsePostfix(rpn)
+			if err != nil {
+				t.Fatalf("ParsePostfix(%q): %v", rpn, err)
+			}
+			got, err := Postfix(back)
+			if err != nil {
+				t.Fatalf("Postfix(ParsePostfix(%q)): %v", rpn, err)
+			}
+			want := tt.want
+			if want == "" {
+				want = rpn
+			}
+			if got != want {
+				t.Errorf("Postfix(ParsePostfix(%q)) = %q, want %q", rpn, got, want)
+			}
+		})
+	}
+}
+
+func TestParsePostfixErrors(t *testing.T) {
+	tests := []string{"", "1 +", "~", "1 2", "1 x +", "1 sqrt@2", "1 foo@1", "sqrt@1", "1 sqrt@1@x"}
+	for _, rpn := range tests {
+		t.Run(rpn, func(t *testing.T) {
+			if _, err := ParsePostfix(rpn); err == nil {
+				t.Errorf("ParsePostfix(%q) succeeded, want error", rpn)
+			}
+		})
+	}
+}
+
+// badNode - узел, которого нет в обратной польской записи
+type badNode struct{ at int }
+
+func (n *badNode) Pos() int { return n.at }
+func (*badNode) node()      {}
+
+func TestPostfixUnknownNode(t *testing.T) {
+	n := &Binary{Op: "+", X: &Number{Value: 1, Lit: "1"}, Y: &badNode{at: 4}}
+	_, err := Postfix(n)
+	var perr *Error
+	if !errors.As(err, &perr) {
+		t.Fatalf("Postfix error = %v, want *Error", err)
+	}
+	if perr.Pos != 4 {
+		t.Errorf("error pos = %d, want 4", perr.Pos)
+	}
+}
+
+func TestParsePostfixCallPos(t *testing.T) {
+	tests := []struct {
+		rpn string
+		pos int
+	}{
+		{rpn: "1 2 max@2@7", pos: 7},
+		// запись без позиции вызова: позиция - номер лексемы
+		{rpn: "1 2 max@2", pos: 2},
+	}
+	for _, tt := range tests {
+		n, err := ParsePostfix(tt.rpn)
+		if err != nil {
+			t.Fatalf("ParsePostfix(%q): %v", tt.rpn, err)
+		}
+		if got := n.Pos(); got != tt.pos {
+			t.Errorf("ParsePostfix(%q) call pos = %d, want %d", tt.rpn, got, tt.pos)
+		}
+	}
+}
+
+func TestPostfixUnboundVar(t *testing.T) {
+	n, err := Parse("1 + x")
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	_, err = Postfix(n)
+	var perr *Error
+	if !errors.As(err, &perr) {
+		t.Fatalf("Postfix error = %v, want *Error", err)
+	}
+	if perr.Pos != 4 || perr.Msg != `unbound variable "x"` {
+		t.Errorf("Postfix error = %d %q, want 4 %q", perr.Pos, perr.Msg, `unbound variable "x"`)
+	}
+}
@@ -0,0 +1,370 @@
+package orch
+
+import (
+	"fmt"
+
+	"github.com/kms-qwe/DAEC/internal/domain/models"
+	"github.com/kms-qwe/DAEC/internal/expr"
+	daecv1 "github.com/kms-qwe/DAEC/internal/protos/gen/go/daec"
+)
+
+// operand - аргумент операции: либо известное значение, либо результат другой задачи
+type operand struct {
+	task  *task
+	value float64
+}
+
+// task - одна операция выражения, вершина графа зависимостей
+type task struct {
+	g  *graph
+	id int64
+	op string
+	// pos - позиция вызова функции в исходном выражении, -1 у операторов
+	pos    int
+	args   []operand
+	parent *task
+	argIdx int
+	done   bool
+	result float64
+	// status - состояние задачи в бд: pending, leased, done, error
+	status string
+	// attempt - сколько раз задача выдавалась агентам
+	attempt int
+	leases  []string
+	// agentID - агент, получивший задачу последним
+	agentID string
+}
+
+// isReady - все аргументы задачи вычислены
+func (t *task) isReady() bool {
+	for _, a := range t.args {
+		if a.task != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// isLocal - задача вычисляется оркестратором без отправки агенту
+func (t *task) isLocal() bool {
+	return t.op == expr.OpNeg
+}
+
+// describe - запись операции задачи с аргументами для сообщений об ошибках,
+// в том же виде, что и у агента: x op y для операторов, op(x, y, ...) для функций
+func (t *task) describe() string {
+	args := make([]float64, len(t.args))
+	for i, a := range t.args {
+		args[i] = a.value
+	}
+	if _, ok := expr.Funcs[t.op]; !ok {
+		switch len(args) {
+		case 1:
+			return fmt.Sprintf("-%g", args[0])
+		case 2:
+			return fmt.Sprintf("%g %s %g", args[0], t.op, args[1])
+		}
+	}
+	return expr.FormatCall(t.op, args)
+}
+
+// reason - причина ошибки выражения из кода и сообщения ошибки задачи;
+// у вызова функции к сообщению добавляется его позиция в исходном выражении
+func (t *task) reason(code, msg string) string {
+	if t.pos >= 0 {
+		return fmt.Sprintf("%s: pos %d: %s", code, t.pos, msg)
+	}
+	return fmt.Sprintf("%s: %s", code, msg)
+}
+
+// toResponse - задача для агента; по (expr_id, node_id, attempt, lease_token)
+// оркестратор сопоставляет результат с выданной задачей
+func (t *task) toResponse(token string) *daecv1.TaskResponse {
+	resp := &daecv1.TaskResponse{
+		Operation:  t.op,
+		ExprId:     t.g.exprID,
+		NodeId:     t.id,
+		Attempt:    int32(t.attempt + 1),
+		LeaseToken: token,
+		Args:       make([]float64, len(t.args)),
+	}
+	for i, a := range t.args {
+		resp.Args[i] = a.value
+	}
+	if _, ok := expr.Funcs[t.op]; !ok && len(t.args) == 2 {
+		resp.Arg1, resp.Arg2 = t.args[0].value, t.args[1].value
+	}
+	return resp
+}
+
+// graph - граф задач одного выражения; задача готова к отправке,
+// как только вычислены все ее аргументы
+type graph struct {
+	exprID int64
+	root   operand
+	tasks  map[int64]*task
+	left   int
+	// failed - выражение завершилось ошибкой или отменено, его задачи больше не выдаются
+	failed bool
+}
+
+// newGraph - строит граф задач по дереву выражения,
+// идентификаторы задач - номера узлов при обходе в глубину (с 1)
+func newGraph(exprID int64, tree expr.Node) (*graph, error) {
+	g := &graph{exprID: exprID, tasks: map[int64]*task{}}
+	root, err := g.build(tree)
+	if err != nil {
+		return nil, err
+	}
+	g.root = root
+	return g, nil
+}
+
+func (g *graph) build(n expr.Node) (operand, error) {
+	switch n := n.(type) {
+	case *expr.Number:
+		return operand{value: n.Value}, nil
+	case *expr.Group:
+		return g.build(n.X)
+	case *expr.Unary:
+		x, err := g.build(n.X)
+		if err != nil {
+			return operand{}, err
+		}
+		if n.Op == "+" {
+			return x, nil
+		}
+		if x.task == nil {
+			return operand{value: -x.value}, nil
+		}
+		return g.add(expr.OpNeg, x), nil
+	case *expr.Binary:
+		x, err := g.build(n.X)
+		if err != nil {
+			return operand{}, err
+		}
+		y, err := g.build(n.Y)
+		if err != nil {
+			return operand{}, err
+		}
+		return g.add(n.Op, x, y), nil
+	case *expr.Call:
+		args := make([]operand, len(n.Args))
+		for i, a := range n.Args {
+			x, err := g.build(a)
+			if err != nil {
+				return operand{}, err
+			}
+			args[i] = x
+		}
+		call := g.add(n.Name, args...)
+		call.task.pos = n.Pos()
+		return call, nil
+	}
+	return operand{}, fmt.Errorf("unsupported node %T", n)
+}
+
+func (g *graph) add(op string, args ...operand) operand {
+	t := &task{g: g, id: int64(len(g.tasks) + 1), op: op, pos: -1, args: args, status: models.TaskPending}
+	for i, a := range args {
+		if a.task != nil {
+			a.task.parent = t
+			a.task.argIdx = i
+		}
+	}
+	g.tasks[t.id] = t
+	g.left++
+	return operand{task: t}
+}
+
+// finished - выражение вычислено полностью
+func (g *graph) finished() bool {
+	return g.left == 0
+}
+
+// complete - сохраняет результат задачи и возвращает задачи, которые стали
+// готовы к отправке благодаря этому результату, и все измененные задачи
+func (g *graph) complete(id int64, result float64) (ready, changed []*task, err error) {
+	t, ok := g.tasks[id]
+	if !ok {
+		return nil, nil, fmt.Errorf("expr %d: unknown task %d", g.exprID, id)
+	}
+	if t.done {
+		return nil, nil, fmt.Errorf("expr %d: task %d is already done", g.exprID, id)
+	}
+	if !t.isReady() {
+		return nil, nil, fmt.Errorf("expr %d: task %d is not ready", g.exprID, id)
+	}
+
+	for t != nil {
+		t.done = true
+		t.result = result
+		t.status = models.TaskDone
+		changed = append(changed, t)
+		g.left--
+		if t.parent == nil {
+			g.root = operand{value: result}
+			break
+		}
+		p := t.parent
+		p.args[t.argIdx] = operand{value: result}
+		changed = append(changed, p)
+		if !p.isReady() {
+			break
+		}
+		if !p.isLocal() {
+			ready = append(ready, p)
+			break
+		}
+		// унарный минус считаем сразу, не отправляя агенту
+		t, result = p, -p.args[0].value
+	}
+	return ready, changed, nil
+}
+
+// model - задача в виде строки бд
+func (t *task) model() models.Task {
+	m := models.Task{
+		ExprID:  t.g.exprID,
+		NodeID:  t.id,
+		Op:      t.op,
+		Pos:     t.pos,
+		Status:  t.status,
+		AgentID: t.agentID,
+		Attempt: t.attempt,
+	}
+	if t.parent != nil {
+		m.ParentID, m.ArgIdx = t.parent.id, t.argIdx
+	}
+	for _, a := range t.args {
+		if a.task != nil {
+			m.Args = append(m.Args, nil)
+			continue
+		}
+		v := a.value
+		m.Args = append(m.Args, &v)
+	}
+	if n := len(t.leases); n > 0 {
+		m.LeaseToken = t.leases[n-1]
+	}
+	if t.done {
+		r := t.result
+		m.Result = &r
+	}
+	return m
+}
+
+// models - все задачи графа для сохранения в бд
+func (g *graph) models() []models.Task {
+	res := make([]models.Task, 0, len(g.tasks))
+	for id := int64(1); id <= int64(len(g.tasks)); id++ {
+		res = append(res, g.tasks[id].model())
+	}
+	return res
+}
+
+// restoreGraph - восстанавливает граф выражения из сохраненных задач
+func restoreGraph(exprID int64, rows []models.Task) (*graph, error) {
+	g := &graph{exprID: exprID, tasks: map[int64]*task{}}
+	// set[id][i] - аргумент i задачи id известен
+	set := map[int64][]bool{}
+	for _, r := range rows {
+		t := &task{
+			g:       g,
+			id:      r.NodeID,
+			op:      r.Op,
+			pos:     r.Pos,
+			args:    make([]operand, len(r.Args)),
+			done:    r.Status == models.TaskDone,
+			status:  r.Status,
+			attempt: r.Attempt,
+			agentID: r.AgentID,
+		}
+		if r.LeaseToken != "" {
+			t.leases = []string{r.LeaseToken}
+		}
+		set[t.id] = make([]bool, len(r.Args))
+		for i, a := range r.Args {
+			if a != nil {
+				t.args[i], set[t.id][i] = operand{value: *a}, true
+			}
+		}
+		if t.done {
+			if r.Result == nil {
+				return nil, fmt.Errorf("task %d is done without result", t.id)
+			}
+			t.result = *r.Result
+		} else {
+			g.left++
+		}
+		g.tasks[t.id] = t
+	}
+
+	var roots int
+	for _, r := range rows {
+		t := g.tasks[r.NodeID]
+		if r.ParentID == 0 {
+			roots++
+			g.root = operand{task: t}
+			if t.done {
+				g.root = operand{value: t.result}
+			}
+			continue
+		}
+		p, ok := g.tasks[r.ParentID]
+		if !ok || r.ArgIdx < 0 || r.ArgIdx >= len(p.args) {
+			return nil, fmt.Errorf("task %d: bad parent %d/%d", t.id, r.ParentID, r.ArgIdx)
+		}
+		t.parent, t.argIdx = p, r.ArgIdx
+		if set[p.id][t.argIdx] {
+			continue
+		}
+		set[p.id][t.argIdx] = true
+		if t.done {
+			// результат записан в задачу, но не успел попасть в аргумент родителя
+			p.args[t.argIdx] = operand{value: t.result}
+		} else {
+			p.args[t.argIdx] = operand{task: t}
+		}
+	}
+	if roots != 1 {
+		return nil, fmt.Errorf("expected one root task, got %d", roots)
+	}
+	for id, args := range set {
+		for i, ok := range args {
+			if !ok && !g.tasks[id].done {
+				return nil, fmt.Errorf("task %d: argument %d is missing", id, i)
+			}
+		}
+	}
+	return g, nil
+}
+
+// foldLocal - вычисляет локальные задачи, готовые после восстановления:
+// аргумент унарного минуса мог быть сохранен вычисленным, а сам минус - нет.
+// Такие задачи не должны попасть в очередь, агенты их не выполняют.
+// Возвращает измененные задачи
+func (g *graph) foldLocal() []*task {
+	var changed []*task
+	for id := int64(1); id <= int64(len(g.tasks)); id++ {
+		t := g.tasks[id]
+		if t.done || !t.isLocal() || !t.isReady() {
+			continue
+		}
+		// задача не вычислена и готова, поэтому complete не вернет ошибку
+		_, c, _ := g.complete(id, -t.args[0].value)
+		changed = append(changed, c...)
+	}
+	return changed
+}
+
+// pending - невычисленные задачи, готовые к отправке, кроме уже выданных агентам
+func (g *graph) pending() []*task {
+	var res []*task
+	for id := int64(1); id <= int64(len(g.tasks)); id++ {
+		if t := g.tasks[id]; !t.done && t.status != models.TaskLeased && t.isReady() {
+			res = append(res, t)
+		}
+	}
+	return res
+}
@@ -0,0 +1,213 @@
+package orch
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/kms-qwe/DAEC/internal/domain/models"
+	"github.com/kms-qwe/DAEC/internal/expr"
+)
+
+// buildGraph - граф выражения src без очереди и хранилища
+func buildGraph(t *testing.T, src string) *graph {
+	t.Helper()
+	tree, err := expr.Parse(src)
+	if err != nil {
+		t.Fatalf("Parse(%q): %v", src, err)
+	}
+	g, err := newGraph(1, tree)
+	if err != nil {
+		t.Fatalf("newGraph(%q): %v", src, err)
+	}
+	return g
+}
+
+func ids(tasks []*task) []int64 {
+	var res []int64
+	for _, t := range tasks {
+		res = append(res, t.id)
+	}
+	return res
+}
+
+func args(t *task) []float64 {
+	res := make([]float64, len(t.args))
+	for i, a := range t.args {
+		res[i] = a.value
+	}
+	return res
+}
+
+func ptr(v float64) *float64 {
+	return &v
+}
+
+func TestComplete(t *testing.T) {
+	// 1: 1 + 2, 2: ~(1), 3: (2) * 3
+	g := buildGraph(t, "-(1 + 2) * 3")
+	if got := ids(g.pending()); !slices.Equal(got, []int64{1}) {
+		t.Fatalf("pending = %v, want [1]", got)
+	}
+	if _, _, err := g.complete(3, 0); err == nil {
+		t.Error("complete of a task that is not ready succeeded")
+	}
+	if _, _, err := g.complete(9, 0); err == nil {
+		t.Error("complete of an unknown task succeeded")
+	}
+
+	ready, changed, err := g.complete(1, 3)
+	if err != nil {
+		t.Fatalf("complete(1): %v", err)
+	}
+	if got := ids(ready); !slices.Equal(got, []int64{3}) {
+		t.Errorf("ready = %v, want [3]", got)
+	}
+	if got := ids(changed); !slices.Contains(got, 2) || !slices.Contains(got, 3) {
+		t.Errorf("changed = %v, want the folded negation and its parent", got)
+	}
+	if neg := g.tasks[2]; !neg.done || neg.result != -3 {
+		t.Errorf("negation done = %v, result = %g, want folded to -3", neg.done, neg.result)
+	}
+	if got := args(g.tasks[3]); !slices.Equal(got, []float64{-3, 3}) {
+		t.Errorf("args of task 3 = %v, want [-3 3]", got)
+	}
+	if _, _, err := g.complete(1, 3); err == nil {
+		t.Error("second complete of a done task succeeded")
+	}
+
+	if _, _, err := g.complete(3, -9); err != nil {
+		t.Fatalf("complete(3): %v", err)
+	}
+	if !g.finished() || g.root.value != -9 {
+		t.Errorf("finished = %v, root = %g, want -9", g.finished(), g.root.value)
+	}
+}
+
+func TestRestoreGraph(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     string
+		edit    func(rows []models.Task)
+		pending []int64
+		args    []float64
+		left    int
+	}{
+		{
+			name:    "nothing computed",
+			src:     "-(1 + 2) * 3",
+			edit:    func([]models.Task) {},
+			pending: []int64{1},
+			args:    []float64{1, 2},
+			left:    3,
+		},
+		{
+			name: "result not copied to negation",
+			src:  "-(1 + 2) * 3",
+			edit: func(rows []models.Task) {
+				rows[0].Status, rows[0].Result = models.TaskDone, ptr(3)
+			},
+			pending: []int64{3},
+			args:    []float64{-3, 3},
+			left:    1,
+		},
+		{
+			name: "negation ready but not folded",
+			src:  "-(1 + 2) * 3",
+			edit: func(rows []models.Task) {
+				rows[0].Status, rows[0].Result = models.TaskDone, ptr(3)
+				rows[1].Args = []*float64{ptr(3)}
+			},
+			pending: []int64{3},
+			args:    []float64{-3, 3},
+			left:    1,
+		},
+		{
+			name: "double negation",
+			src:  "-(-(1 + 2)) + 1",
+			edit: func(rows []models.Task) {
+				rows[0].Status, rows[0].Result = models.TaskDone, ptr(3)
+			},
+			pending: []int64{4},
+			args:    []float64{3, 1},
+			left:    1,
+		},
+		{
+			name: "leased task is not pending",
+			src:  "(1 + 2) * (3 + 4)",
+			edit: func(rows []models.Task) {
+				rows[0].Status, rows[0].LeaseToken, rows[0].Attempt = models.TaskLeased, "l1", 1
+			},
+			pending: []int64{2},
+			args:    []float64{3, 4},
+			left:    3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows := buildGraph(t, tt.src).models()
+			tt.edit(rows)
+
+			g, err := restoreGraph(1, rows)
+			if err != nil {
+				t.Fatalf("restoreGraph: %v", err)
+			}
+			for _, tsk := range g.foldLocal() {
+				if !tsk.done && tsk.isLocal() {
+					t.Errorf("changed negation %d is not done", tsk.id)
+				}
+			}
+			pending := g.pending()
+			if got := ids(pending); !slices.Equal(got, tt.pending) {
+				t.Fatalf("pending = %v, want %v", got, tt.pending)
+			}
+			if got := args(pending[0]); !slices.Equal(got, tt.args) {
+				t.Errorf("args of task %d = %v, want %v", pending[0].id, got, tt.args)
+			}
+			if g.left != tt.left {
+				t.Errorf("left = %d, want %d", g.left, tt.left)
+			}
+		})
+	}
+}
+
+func TestRestoreGraphErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		edit func(rows []models.Task)
+	}{
+		{name: "two roots", edit: func(rows []models.Task) { rows[0].ParentID = 0 }},
+		{name: "bad parent", edit: func(rows []models.Task) { rows[0].ParentID = 9 }},
+		{name: "bad argument index", edit: func(rows []models.Task) { rows[0].ArgIdx = 5 }},
+		{name: "done without result", edit: func(rows []models.Task) { rows[0].Status = models.TaskDone }},
+		{name: "missing argument", edit: func(rows []models.Task) { rows[2].Args = append(rows[2].Args, nil) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows := buildGraph(t, "-(1 + 2) * 3").models()
+			tt.edit(rows)
+			if _, err := restoreGraph(1, rows); err == nil {
+				t.Error("restoreGraph succeeded")
+			}
+		})
+	}
+}
+
+func TestFillFoldsRestoredNegation(t *testing.T) {
+	st := newFakeStorage()
+	rows := buildGraph(t, "-(1 + 2) * 3").models()
+	rows[0].Status, rows[0].Result = models.TaskDone, ptr(3)
+	st.UpdateTasks(context.Background(), rows)
+	st.exprs = []models.Expr{{ID: 1, PolishExpr: "1 2 + ~ 3 *"}}
+
+	tp := newTestPuller(st)
+	tp.MaxInFlight = 1
+	tp.fill(context.Background(), tp.Log)
+
+	if got := ids(tp.queue); !slices.Equal(got, []int64{3}) {
+		t.Fatalf("queue = %v, want [3]", got)
+	}
+	if neg := st.tasks[1][2]; neg.Status != models.TaskDone || neg.Result == nil || *neg.Result != -3 {
+		t.Errorf("saved negation = %+v, want done with -3", neg)
+	}
+}
@@ -0,0 +1,120 @@
+package orch
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"time"
+
+	"github.com/kms-qwe/DAEC/internal/domain/models"
+)
+
+// lease - задача, выданная агенту; если результат не пришел до deadline,
+// задача возвращается в очередь и выдается другому агенту
+type lease struct {
+	token    string
+	task     *task
+	attempt  int
+	deadline time.Time
+	expired  bool
+	// session - сессия агента, получившая задачу; nil для GiveTask
+	session *Session
+}
+
+// newLeaseToken - случайный токен аренды; в отличие от номера попытки
+// не повторяется и после перезапуска оркестратора
+func newLeaseToken() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic("can't generate lease token: " + err.Error())
+	}
+	return hex.EncodeToString(b)
+}
+
+// leaseTTL - срок аренды задачи: время операции из конфига плюс запас
+func (t *TaskPuller) leaseTTL(op string) time.Duration {
+	return t.OpTimes[op] + t.LeaseGrace
+}
+
+// grant - выдает аренду на отправленную агенту задачу; sess - nil для GiveTask
+func (t *TaskPuller) grant(token string, tsk *task, sess *Session, now time.Time) *lease {
+	tsk.attempt++
+	l := &lease{token: token, task: tsk, attempt: tsk.attempt, deadline: now.Add(t.leaseTTL(tsk.op)), session: sess}
+	tsk.leases = append(tsk.leases, token)
+	tsk.status = models.TaskLeased
+	tsk.agentID = ""
+	if sess != nil {
+		tsk.agentID = sess.agent.id
+	}
+	t.leases[token] = l
+	return l
+}
+
+// restoreLeases - после перезапуска снова принимает результаты по арендам,
+// выданным до него: агент, переподключившись, досылает вычисленные результаты.
+// Срок аренды отсчитывается заново
+func (t *TaskPuller) restoreLeases(g *graph, now time.Time) {
+	for _, tsk := range g.tasks {
+		if tsk.status != models.TaskLeased {
+			continue
+		}
+		if len(tsk.leases) == 0 {
+			tsk.status = models.TaskPending
+			continue
+		}
+		token := tsk.leases[0]
+		t.leases[token] = &lease{token: token, task: tsk, attempt: tsk.attempt, deadline: now.Add(t.leaseTTL(tsk.op))}
+	}
+}
+
+// expire - возвращает в начало очереди задачи с истекшей арендой
+func (t *TaskPuller) expire(ctx context.Context, log *slog.Logger, now time.Time) {
+	t.revoke(ctx, log, "аренда задачи истекла, задача возвращена в очередь", func(l *lease) bool {
+		return !now.Before(l.deadline)
+	})
+}
+
+// revoke - досрочно завершает подходящие аренды и возвращает их задачи
+// в начало очереди. Аренда не удаляется: опоздавший результат по ней все
+// еще принимается, если задача к тому времени не вычислена по другой аренде
+func (t *TaskPuller) revoke(ctx context.Context, log *slog.Logger, msg string, match func(*lease) bool) {
+	var requeue []*task
+	for _, l := range t.leases {
+		if l.expired || l.task.done || !match(l) {
+			continue
+		}
+		l.expired = true
+		if l.task.hasLiveLease(t.leases) {
+			continue
+		}
+		log.Info(
+			msg,
+			slog.Int64("expr", l.task.g.exprID),
+			slog.Int64("task", l.task.id),
+			slog.Int("attempt", l.attempt),
+		)
+		l.task.status = models.TaskPending
+		requeue = append(requeue, l.task)
+	}
+	t.persist(ctx, log, requeue...)
+	t.queue = append(requeue, t.queue...)
+}
+
+// release - удаляет все аренды вычисленной задачи
+func (t *TaskPuller) release(tsk *task) {
+	for _, id := range tsk.leases {
+		delete(t.leases, id)
+	}
+	tsk.leases = nil
+}
+
+// hasLiveLease - у задачи есть неистекшая аренда
+func (tsk *task) hasLiveLease(leases map[string]*lease) bool {
+	for _, id := range tsk.leases {
+		if l, ok := leases[id]; ok && !l.expired {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,120 @@
+package orch
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/kms-qwe/DAEC/internal/domain/models"
+	daecv1 "github.com/kms-qwe/DAEC/internal/protos/gen/go/daec"
+)
+
+func TestLeaseExpiry(t *testing.T) {
+	ctx := context.Background()
+	st := newFakeStorage()
+	tp := newTestPuller(st)
+	tp.OpTimes["+"] = time.Second
+	addExpr(t, tp, 1, "1 + 2")
+	now := time.Now()
+	tsk := lease1(tp, "l1", now)
+
+	// срок аренды - время операции плюс LeaseGrace
+	tp.expire(ctx, tp.Log, now.Add(2*time.Second-time.Millisecond))
+	if len(tp.queue) != 0 || tsk.status != models.TaskLeased {
+		t.Fatalf("task requeued before the lease deadline: queue = %v, status = %s", ids(tp.queue), tsk.status)
+	}
+
+	tp.expire(ctx, tp.Log, now.Add(2*time.Second))
+	if got := ids(tp.queue); !slices.Equal(got, []int64{tsk.id}) {
+		t.Fatalf("queue = %v, want [%d]", got, tsk.id)
+	}
+	if st.tasks[1][tsk.id].Status != models.TaskPending {
+		t.Errorf("saved status = %s, want %s", st.tasks[1][tsk.id].Status, models.TaskPending)
+	}
+
+	// повторная выдача - новая попытка; результат по ней принимается
+	if got := lease1(tp, "l2", now.Add(2*time.Second)); got != tsk || tsk.attempt != 2 {
+		t.Fatalf("redelivered task %d attempt %d, want task %d attempt 2", got.id, tsk.attempt, tsk.id)
+	}
+	err := tp.handleResult(ctx, tp.Log, &daecv1.ResultRequest{Result: 3, ExprId: 1, NodeId: tsk.id, Attempt: 1, LeaseToken: "l2"})
+	if !errors.Is(err, ErrLeaseMismatch) {
+		t.Errorf("result with a stale attempt: %v, want %v", err, ErrLeaseMismatch)
+	}
+	err = tp.handleResult(ctx, tp.Log, &daecv1.ResultRequest{Result: 3, ExprId: 1, NodeId: tsk.id, Attempt: 2, LeaseToken: "l2"})
+	if err != nil {
+		t.Fatalf("handleResult: %v", err)
+	}
+	if st.results[1] != 3 {
+		t.Errorf("result = %g, want 3", st.results[1])
+	}
+
+	// опоздавший результат по истекшей аренде отклоняется: задача уже вычислена
+	err = tp.handleResult(ctx, tp.Log, &daecv1.ResultRequest{Result: 3, ExprId: 1, NodeId: tsk.id, Attempt: 1, LeaseToken: "l1"})
+	if !errors.Is(err, ErrUnknownLease) {
+		t.Errorf("late result: %v, want %v", err, ErrUnknownLease)
+	}
+	if len(tp.leases) != 0 {
+		t.Errorf("leases left: %d", len(tp.leases))
+	}
+}
+
+func TestLateResultAfterExpiry(t *testing.T) {
+	ctx := context.Background()
+	st := newFakeStorage()
+	tp := newTestPuller(st)
+	addExpr(t, tp, 1, "1 + 2")
+	now := time.Now()
+	tsk := lease1(tp, "l1", now)
+	tp.expire(ctx, tp.Log, now.Add(time.Minute))
+	lease1(tp, "l2", now.Add(time.Minute))
+
+	// задача еще не вычислена по новой аренде, поэтому результат по истекшей принимается
+	err := tp.handleResult(ctx, tp.Log, &daecv1.ResultRequest{Result: 3, ExprId: 1, NodeId: tsk.id, Attempt: 1, LeaseToken: "l1"})
+	if err != nil {
+		t.Fatalf("late result: %v", err)
+	}
+	if st.results[1] != 3 {
+		t.Errorf("result = %g, want 3", st.results[1])
+	}
+	err = tp.handleResult(ctx, tp.Log, &daecv1.ResultRequest{Result: 3, ExprId: 1, NodeId: tsk.id, Attempt: 2, LeaseToken: "l2"})
+	if !errors.Is(err, ErrUnknownLease) {
+		t.Errorf("result by the second lease: %v, want %v", err, ErrUnknownLease)
+	}
+}
+
+func TestRestoreLeases(t *testing.T) {
+	ctx := context.Background()
+	rows := buildGraph(t, "(1 + 2) * (3 + 4)").models()
+	rows[0].Status, rows[0].LeaseToken, rows[0].Attempt = models.TaskLeased, "l1", 1
+	// выдана, но токен не сохранен: такая аренда не восстанавливается
+	rows[1].Status, rows[1].Attempt = models.TaskLeased, 1
+	st := newFakeStorage()
+	st.UpdateTasks(ctx, rows)
+	st.exprs = []models.Expr{{ID: 1}}
+
+	tp := newTestPuller(st)
+	tp.MaxInFlight = 1
+	tp.fill(ctx, tp.Log)
+
+	if got := ids(tp.queue); !slices.Equal(got, []int64{2}) {
+		t.Fatalf("queue = %v, want [2]", got)
+	}
+	l, ok := tp.leases["l1"]
+	if !ok {
+		t.Fatal("lease l1 is not restored")
+	}
+	if l.task.id != 1 || l.attempt != 1 {
+		t.Errorf("lease l1 on task %d attempt %d, want task 1 attempt 1", l.task.id, l.attempt)
+	}
+
+	// агент досылает результат по аренде, выданной до перезапуска
+	err := tp.handleResult(ctx, tp.Log, &daecv1.ResultRequest{Result: 3, ExprId: 1, NodeId: 1, Attempt: 1, LeaseToken: "l1"})
+	if err != nil {
+		t.Fatalf("handleResult: %v", err)
+	}
+	if got := args(tp.exprs[1].tasks[3]); got[0] != 3 {
+		t.Errorf("args of task 3 = %v, want the restored result", got)
+	}
+}
@@ -2,14 +2,18 @@ package orch
 
 import (
 	"context"
+	"errors"
 	"log/slog"
-	"strconv"
-	"strings"
+	"math"
 	"time"
 
+	"github.com/kms-qwe/DAEC/internal/domain/models"
+	"github.com/kms-qwe/DAEC/internal/expr"
 	"github.com/kms-qwe/DAEC/internal/lib/logger/sl"
 	daecv1 "github.com/kms-qwe/DAEC/internal/protos/gen/go/daec"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 type ServerApi struct {
@@ -20,132 +24,425 @@ type ServerApi struct {
 type TaskPuller struct {
 	Log         *slog.Logger
 	ChToAgent   chan *daecv1.TaskResponse
-	ChFromAgent chan *daecv1.ResultRequest
-	ExprID      int64
-	Expr        string
-	ExpStrg     ExpStorage
+	ChFromAgent chan *Result
+	// ChSession - события потоковых сессий агентов
+	ChSession chan SessionEvent
+	// ChAdmin - запросы снимка реестра агентов
+	ChAdmin chan chan []*daecv1.AgentInfo
+	ExpStrg ExpStorage
+	// MaxInFlight - сколько выражений вычисляется одновременно
+	MaxInFlight int
+	// PollInterval - как часто проверять бд на новые выражения и истекшие аренды
+	PollInterval time.Duration
+	// OpTimes - время выполнения операции агентом, из него считается срок аренды
+	OpTimes map[string]time.Duration
+	// LeaseGrace - запас к сроку аренды сверх времени операции
+	LeaseGrace time.Duration
+	// AgentTimeout - агент без heartbeat дольше этого срока считается мертвым
+	AgentTimeout time.Duration
+	// DrainTimeout - сколько при остановке ждать результатов уже выданных задач
+	DrainTimeout time.Duration
+	// Stopped закрывается, когда Eval завершился
+	Stopped chan struct{}
+
+	agents   map[string]*agentState
+	exprs    map[int64]*graph
+	queue    []*task
+	leases   map[string]*lease
+	sessions []*Session
+	rr       int
+}
+
+// Result - результат агента и канал, в который оркестратор
+// сообщает, принят ли результат
+type Result struct {
+	Req *daecv1.ResultRequest
+	Ack chan error
 }
 
+// exprComputing - статус выражения, которое еще вычисляется
+const exprComputing = "computing"
+
+var (
+	ErrUnknownLease  = errors.New("no outstanding task for lease")
+	ErrLeaseMismatch = errors.New("result does not match leased task")
+	ErrStopped       = errors.New("orchestrator is stopping")
+)
+
 type ExpStorage interface {
-	GetExpr(ctx context.Context) (int64, string, error)
-	SaveExpr(ctx context.Context, exprID int64, expr string) error
+	GetExprs(ctx context.Context, limit int, exclude []int64) ([]models.Expr, error)
+	SaveResult(ctx context.Context, exprID int64, result float64) error
+	FailExpr(ctx context.Context, exprID int64, reason string) error
+	SaveTasks(ctx context.Context, tasks []models.Task) error
+	UpdateTasks(ctx context.Context, tasks []models.Task) error
+	GetTasks(ctx context.Context, exprID int64) ([]models.Task, error)
+	GetExprStatuses(ctx context.Context, ids []int64) (map[int64]string, error)
 }
 
 func Register(gRPC *grpc.Server, TaskPull *TaskPuller) {
 	daecv1.RegisterOrchServiceServer(gRPC, &ServerApi{TaskPull: TaskPull})
+	daecv1.RegisterAdminServiceServer(gRPC, &AdminApi{TaskPull: TaskPull})
 }
 
+// GiveTask - выдает одну задачу; оставлен для агентов без потоковой сессии
 func (s *ServerApi) GiveTask(ctx context.Context, req *daecv1.TaskRequest) (*daecv1.TaskResponse, error) {
-	return <-s.TaskPull.ChToAgent, nil
+	select {
+	case tsk := <-s.TaskPull.ChToAgent:
+		return tsk, nil
+	case <-s.TaskPull.Stopped:
+		return nil, status.Error(codes.Unavailable, ErrStopped.Error())
+	case <-ctx.Done():
+		return nil, status.FromContextError(ctx.Err()).Err()
+	}
 }
 
 func (s *ServerApi) GetResult(ctx context.Context, req *daecv1.ResultRequest) (*daecv1.ResultResponse, error) {
-	s.TaskPull.ChFromAgent <- req
+	err := s.TaskPull.submit(ctx, req)
+	switch {
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return nil, status.FromContextError(err).Err()
+	case errors.Is(err, ErrUnknownLease):
+		return nil, status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, ErrLeaseMismatch):
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, ErrStopped):
+		return nil, status.Error(codes.Unavailable, err.Error())
+	case err != nil:
+		return nil, status.Error(codes.Internal, err.Error())
+	}
 	return &daecv1.ResultResponse{}, nil
 }
-func (t *TaskPuller) FakeEval() {
-	const op = "orch.FakeEval"
-	log := t.Log.With(
-		slog.String("op", op),
-	)
-	log.Info("Eval starts")
-	go func() {
-		for {
-			t.ChToAgent <- &daecv1.TaskResponse{}
-			log.Info("Задача отослана")
-			time.Sleep(1 * time.Second)
-		}
-	}()
-	for m := range t.ChFromAgent {
-		log.Info("get m", slog.Any("m", m))
+
+// submit - передает результат агента в цикл Eval и ждет, принят ли он
+func (t *TaskPuller) submit(ctx context.Context, req *daecv1.ResultRequest) error {
+	res := &Result{Req: req, Ack: make(chan error, 1)}
+	select {
+	case t.ChFromAgent <- res:
+	case <-t.Stopped:
+		return ErrStopped
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case err := <-res.Ack:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
 	}
 }
-func (t *TaskPuller) Eval() {
+
+// Eval - цикл оркестратора. После отмены stop новые задачи не выдаются,
+// цикл ждет результатов уже выданных задач (не дольше DrainTimeout),
+// закрывает сессии агентов и завершается. Состояние задач к этому моменту
+// уже в бд, невернувшиеся аренды восстановятся при следующем запуске
+func (t *TaskPuller) Eval(stop context.Context) {
 	const op = "orch.Eval"
 	log := t.Log.With(
 		slog.String("op", op),
 	)
 	log.Info("Eval starts")
+	defer close(t.Stopped)
 
 	ctx := context.Background()
-	var err error
+	stopping := stop.Done()
+	var drained <-chan time.Time
+	draining := false
+	t.exprs = map[int64]*graph{}
+	t.leases = map[string]*lease{}
+	t.agents = map[string]*agentState{}
+
+	ticker := time.NewTicker(t.PollInterval)
+	defer ticker.Stop()
 
+	t.fill(ctx, log)
 	for {
-		// time.Sleep(5 * time.Second)
-		t.ExprID, t.Expr, err = t.ExpStrg.GetExpr(ctx)
-		if err != nil {
-			log.Info("falied to get expr", sl.Err(err))
-			time.Sleep(10 * time.Second)
-			continue
+		if draining && t.inFlight() == 0 {
+			log.Info("все выданные задачи вернулись, Eval остановлен")
+			t.closeSessions(log)
+			return
 		}
 
-		log.Info("get expr", slog.String("expr", t.Expr))
-
-		//Считаем части, которые можно выполнить параллельно
-		elementsOfExpr := strings.Fields(t.Expr)
-		numOp := 0
-		res := map[int]float64{}
+		// сначала задачи получают потоковые сессии, остаток - GiveTask
+		if !draining {
+			t.dispatch(ctx, log, time.Now())
+		}
 
-		for i := range len(elementsOfExpr) - 2 {
-			if isNumber(elementsOfExpr[i]) && isNumber(elementsOfExpr[i+1]) && isOperator([]rune(elementsOfExpr[i+2])[0]) {
-				numOp += 1
-			}
+		// задачи, вычисленные по опоздавшей аренде, пока ждали в очереди,
+		// и задачи выражений, завершившихся ошибкой или отмененных
+		for len(t.queue) > 0 && (t.queue[0].done || t.queue[0].g.failed) {
+			t.queue = t.queue[1:]
 		}
 
-		go func() {
-			cnt := 0
-			for i := range len(elementsOfExpr) - 2 {
-				if isNumber(elementsOfExpr[i]) && isNumber(elementsOfExpr[i+1]) && isOperator([]rune(elementsOfExpr[i+2])[0]) {
-					cnt += 1
-					tsk := &daecv1.TaskResponse{Id: int64(cnt)}
-					tsk.Arg1, _ = strconv.ParseFloat(elementsOfExpr[i], 64)
-					tsk.Arg2, _ = strconv.ParseFloat(elementsOfExpr[i+1], 64)
-					tsk.Operation = elementsOfExpr[i+2]
-					log.Info("отправлено в chToAgent", slog.Any("task", tsk))
-					t.ChToAgent <- tsk
-				}
-			}
-		}()
+		// Готовые задачи всех выражений пула стоят в общей очереди,
+		// поэтому агенты получают операции разных выражений вперемешку
+		var out chan *daecv1.TaskResponse
+		var next *daecv1.TaskResponse
+		if len(t.queue) > 0 && !draining {
+			out, next = t.ChToAgent, t.queue[0].toResponse(newLeaseToken())
+		}
 
-		log.Info("ожидается результат", slog.Int("len(res)", len(res)), slog.Int("numOp", numOp))
-		for len(res) != numOp {
-			r := <-t.ChFromAgent
+		select {
+		case out <- next:
+			tsk := t.queue[0]
+			t.queue = t.queue[1:]
+			l := t.grant(next.LeaseToken, tsk, nil, time.Now())
+			t.persist(ctx, log, tsk)
 			log.Info(
-				"Получен новый результат",
-				slog.Int("len(res)",
-					len(res)+1),
-				slog.Int("numOp", numOp),
-				slog.Int64("номер результата", r.Id),
-				slog.Float64("Результат", r.Result),
+				"отправлено в chToAgent",
+				slog.Any("task", next),
+				slog.Time("deadline", l.deadline),
 			)
-			res[int(r.GetId())] = r.GetResult()
+		case r := <-t.ChFromAgent:
+			r.Ack <- t.handleResult(ctx, log, r.Req)
+		case ev := <-t.ChSession:
+			t.handleSession(ctx, log, ev, time.Now())
+		case reply := <-t.ChAdmin:
+			reply <- t.agentInfos()
+		case now := <-ticker.C:
+			t.reap(ctx, log, now)
+			t.expire(ctx, log, now)
+			t.dropCancelled(ctx, log)
+			if !draining {
+				t.fill(ctx, log)
+			}
+		case <-stopping:
+			log.Info("остановка: задачи больше не выдаются, ждем результатов", slog.Int("in_flight", t.inFlight()))
+			draining, stopping = true, nil
+			drained = time.After(t.DrainTimeout)
+		case <-drained:
+			log.Info("остановка: не все результаты получены, аренды восстановятся при запуске", slog.Int("in_flight", t.inFlight()))
+			t.closeSessions(log)
+			return
 		}
+	}
+}
 
-		cnt := 0
-		for i := range len(elementsOfExpr) - 2 {
-			if isNumber(elementsOfExpr[i]) && isNumber(elementsOfExpr[i+1]) && isOperator([]rune(elementsOfExpr[i+2])[0]) {
-				cnt += 1
-				r := res[cnt]
-				strRes := strconv.FormatFloat(r, 'f', 6, 64)
-				elementsOfExpr[i], elementsOfExpr[i+1], elementsOfExpr[i+2] = strRes, "", ""
-			}
+// inFlight - сколько выданных задач ждут результата
+func (t *TaskPuller) inFlight() int {
+	n := 0
+	for _, l := range t.leases {
+		if !l.expired && !l.task.done && !l.task.g.failed {
+			n++
 		}
+	}
+	return n
+}
 
-		expr := strings.Join(elementsOfExpr, " ")
+// fill - добирает в пул новые выражения из бд, пока есть свободные места
+func (t *TaskPuller) fill(ctx context.Context, log *slog.Logger) {
+	free := t.MaxInFlight - len(t.exprs)
+	if free <= 0 {
+		return
+	}
+
+	exclude := make([]int64, 0, len(t.exprs))
+	for id := range t.exprs {
+		exclude = append(exclude, id)
+	}
+	exprs, err := t.ExpStrg.GetExprs(ctx, free, exclude)
+	if err != nil {
+		log.Info("falied to get exprs", sl.Err(err))
+		return
+	}
 
-		err = t.ExpStrg.SaveExpr(ctx, t.ExprID, expr)
+	for _, e := range exprs {
+		g, err := t.load(ctx, log, e)
 		if err != nil {
-			log.Info("falied to save new expr", sl.Err(err))
+			log.Info("falied to build task graph", slog.Int64("expr", e.ID), sl.Err(err))
+			t.fail(ctx, log, &graph{exprID: e.ID}, "invalid_expression: "+err.Error())
+			continue
+		}
+		if g == nil {
+			continue
 		}
-		log.Info("новое выражение сохранено", slog.String("NewExpr", expr))
+
+		if g.finished() {
+			// в выражении нет операций или все они вычислены до перезапуска
+			t.save(ctx, log, g)
+			continue
+		}
+		t.exprs[e.ID] = g
+		t.queue = append(t.queue, g.pending()...)
 	}
 }
 
-func isNumber(str string) bool {
-	_, err := strconv.ParseFloat(str, 64)
-	return err == nil
+// load - граф выражения: восстановленный из сохраненных задач, если
+// выражение начали вычислять до перезапуска, иначе построенный заново.
+// nil без ошибки - задачи не удалось прочитать или сохранить, выражение
+// будет взято снова на следующем тике
+func (t *TaskPuller) load(ctx context.Context, log *slog.Logger, e models.Expr) (*graph, error) {
+	rows, err := t.ExpStrg.GetTasks(ctx, e.ID)
+	if err != nil {
+		log.Info("falied to get tasks", slog.Int64("expr", e.ID), sl.Err(err))
+		return nil, nil
+	}
+
+	if len(rows) > 0 {
+		g, err := restoreGraph(e.ID, rows)
+		if err != nil {
+			return nil, err
+		}
+		t.persist(ctx, log, g.foldLocal()...)
+		t.restoreLeases(g, time.Now())
+		log.Info("выражение восстановлено", slog.Int64("expr", e.ID), slog.Int("left", g.left))
+		return g, nil
+	}
+
+	tree, err := expr.ParsePostfix(e.PolishExpr)
+	if err != nil {
+		return nil, err
+	}
+	g, err := newGraph(e.ID, tree)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.ExpStrg.SaveTasks(ctx, g.models()); err != nil {
+		log.Info("falied to save tasks", slog.Int64("expr", e.ID), sl.Err(err))
+		return nil, nil
+	}
+	log.Info("get expr", slog.Int64("expr", e.ID), slog.String("polish", e.PolishExpr))
+	return g, nil
+}
+
+// handleResult - применяет результат агента к графу выражения.
+// Результат без действующей аренды или не совпадающий с ней отклоняется
+func (t *TaskPuller) handleResult(ctx context.Context, log *slog.Logger, r *daecv1.ResultRequest) error {
+	rlog := log.With(
+		slog.Int64("expr", r.GetExprId()),
+		slog.Int64("node", r.GetNodeId()),
+		slog.Int("attempt", int(r.GetAttempt())),
+	)
+
+	l, ok := t.leases[r.GetLeaseToken()]
+	if !ok {
+		rlog.Info("результат отброшен: нет выданной задачи с такой арендой")
+		return ErrUnknownLease
+	}
+	tsk := l.task
+	if tsk.g.exprID != r.GetExprId() || tsk.id != r.GetNodeId() || l.attempt != int(r.GetAttempt()) {
+		rlog.Info("результат отброшен: не совпадает с выданной задачей", slog.Int64("leased_node", tsk.id))
+		return ErrLeaseMismatch
+	}
+	t.release(tsk)
+	if l.session != nil {
+		if r.GetError() != nil {
+			l.session.agent.failed++
+		} else {
+			l.session.agent.done++
+		}
+	}
+
+	g := tsk.g
+	if taskErr := r.GetError(); taskErr != nil {
+		rlog.Info("агент не смог вычислить задачу", slog.String("code", taskErr.GetCode()), slog.String("message", taskErr.GetMessage()))
+		tsk.status = models.TaskError
+		t.persist(ctx, log, tsk)
+		t.fail(ctx, log, g, tsk.reason(taskErr.GetCode(), taskErr.GetMessage()))
+		return nil
+	}
+	if res := r.GetResult(); math.IsInf(res, 0) || math.IsNaN(res) {
+		rlog.Info("агент вернул нечисловой результат", slog.Float64("Результат", res))
+		tsk.status = models.TaskError
+		t.persist(ctx, log, tsk)
+		reason := tsk.reason("overflow", tsk.describe()+" is out of range")
+		if math.IsNaN(res) {
+			reason = tsk.reason("domain_error", tsk.describe()+" is not a real number")
+		}
+		t.fail(ctx, log, g, reason)
+		return nil
+	}
+
+	ready, changed, err := g.complete(tsk.id, r.GetResult())
+	if err != nil {
+		rlog.Info("результат отброшен", sl.Err(err))
+		return err
+	}
+	t.persist(ctx, log, changed...)
+	rlog.Info(
+		"Получен новый результат",
+		slog.Int("left", g.left),
+		slog.Float64("Результат", r.GetResult()),
+	)
+	t.queue = append(t.queue, ready...)
+
+	if g.finished() {
+		t.save(ctx, log, g)
+		delete(t.exprs, g.exprID)
+	}
+	return nil
 }
 
-func isOperator(c rune) bool {
-	return c == '+' || c == '-' || c == '*' || c == '/' || c == '(' || c == ')'
+// fail - снимает выражение с вычисления: задачи выражения больше не выдаются,
+// опоздавшие результаты отклоняются, в бд сохраняется статус error с причиной
+func (t *TaskPuller) fail(ctx context.Context, log *slog.Logger, g *graph, reason string) {
+	g.failed = true
+	for _, tsk := range g.tasks {
+		t.release(tsk)
+	}
+	delete(t.exprs, g.exprID)
+
+	if err := t.ExpStrg.FailExpr(ctx, g.exprID, reason); err != nil {
+		log.Info("falied to save expr error", slog.Int64("expr", g.exprID), sl.Err(err))
+		return
+	}
+	log.Info("выражение завершилось ошибкой", slog.Int64("expr", g.exprID), slog.String("reason", reason))
+}
+
+// dropCancelled - снимает с вычисления выражения, отмененные или удаленные
+// пользователем: их задачи больше не выдаются, опоздавшие результаты отклоняются
+func (t *TaskPuller) dropCancelled(ctx context.Context, log *slog.Logger) {
+	if len(t.exprs) == 0 {
+		return
+	}
+	ids := make([]int64, 0, len(t.exprs))
+	for id := range t.exprs {
+		ids = append(ids, id)
+	}
+	statuses, err := t.ExpStrg.GetExprStatuses(ctx, ids)
+	if err != nil {
+		log.Info("falied to get expr statuses", sl.Err(err))
+		return
+	}
+
+	for _, id := range ids {
+		status, ok := statuses[id]
+		if ok && status == exprComputing {
+			continue
+		}
+		if !ok {
+			status = "deleted"
+		}
+		g := t.exprs[id]
+		g.failed = true
+		for _, tsk := range g.tasks {
+			t.release(tsk)
+		}
+		delete(t.exprs, id)
+		log.Info("выражение снято с вычисления пользователем", slog.Int64("expr", id), slog.String("status", status))
+	}
+}
+
+// save - сохраняет результат вычисленного выражения, выражение получает статус done
+func (t *TaskPuller) save(ctx context.Context, log *slog.Logger, g *graph) {
+	if err := t.ExpStrg.SaveResult(ctx, g.exprID, g.root.value); err != nil {
+		log.Info("falied to save result", slog.Int64("expr", g.exprID), sl.Err(err))
+		return
+	}
+	log.Info("выражение вычислено", slog.Int64("expr", g.exprID), slog.Float64("result", g.root.value))
+}
+
+// persist - записывает состояние задач в бд, чтобы после перезапуска
+// продолжить вычисление с того же места
+func (t *TaskPuller) persist(ctx context.Context, log *slog.Logger, tasks ...*task) {
+	if len(tasks) == 0 {
+		return
+	}
+	rows := make([]models.Task, 0, len(tasks))
+	for _, tsk := range tasks {
+		rows = append(rows, tsk.model())
+	}
+	if err := t.ExpStrg.UpdateTasks(ctx, rows); err != nil {
+		log.Info("falied to save tasks", slog.Int64("expr", tasks[0].g.exprID), sl.Err(err))
+	}
 }
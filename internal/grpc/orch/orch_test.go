@@ -0,0 +1,201 @@
+package orch
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/kms-qwe/DAEC/internal/domain/models"
+	"github.com/kms-qwe/DAEC/internal/expr"
+	daecv1 "github.com/kms-qwe/DAEC/internal/protos/gen/go/daec"
+)
+
+// fakeStorage - ExpStorage в памяти: отдает exprs один раз, запоминает итог
+// выражений и последнее состояние задач
+type fakeStorage struct {
+	exprs   []models.Expr
+	results map[int64]float64
+	reasons map[int64]string
+	tasks   map[int64]map[int64]models.Task
+}
+
+func newFakeStorage() *fakeStorage {
+	return &fakeStorage{
+		results: map[int64]float64{},
+		reasons: map[int64]string{},
+		tasks:   map[int64]map[int64]models.Task{},
+	}
+}
+
+func (s *fakeStorage) GetExprs(context.Context, int, []int64) ([]models.Expr, error) {
+	exprs := s.exprs
+	s.exprs = nil
+	return exprs, nil
+}
+
+func (s *fakeStorage) SaveResult(_ context.Context, exprID int64, result float64) error {
+	s.results[exprID] = result
+	return nil
+}
+
+func (s *fakeStorage) FailExpr(_ context.Context, exprID int64, reason string) error {
+	s.reasons[exprID] = reason
+	return nil
+}
+
+func (s *fakeStorage) SaveTasks(ctx context.Context, tasks []models.Task) error {
+	return s.UpdateTasks(ctx, tasks)
+}
+
+func (s *fakeStorage) UpdateTasks(_ context.Context, tasks []models.Task) error {
+	for _, t := range tasks {
+		if s.tasks[t.ExprID] == nil {
+			s.tasks[t.ExprID] = map[int64]models.Task{}
+		}
+		s.tasks[t.ExprID][t.NodeID] = t
+	}
+	return nil
+}
+
+func (s *fakeStorage) GetTasks(_ context.Context, exprID int64) ([]models.Task, error) {
+	var rows []models.Task
+	for id := int64(1); id <= int64(len(s.tasks[exprID])); id++ {
+		rows = append(rows, s.tasks[exprID][id])
+	}
+	return rows, nil
+}
+
+func (s *fakeStorage) GetExprStatuses(context.Context, []int64) (map[int64]string, error) {
+	return map[int64]string{}, nil
+}
+
+func newTestPuller(st ExpStorage) *TaskPuller {
+	return &TaskPuller{
+		Log:        slog.New(slog.NewTextHandler(io.Discard, nil)),
+		ExpStrg:    st,
+		OpTimes:    map[string]time.Duration{},
+		LeaseGrace: time.Second,
+		agents:     map[string]*agentState{},
+		exprs:      map[int64]*graph{},
+		leases:     map[string]*lease{},
+	}
+}
+
+// addExpr - строит граф выражения src и ставит его готовые задачи в очередь
+func addExpr(t *testing.T, tp *TaskPuller, exprID int64, src string) *graph {
+	t.Helper()
+	tree, err := expr.Parse(src)
+	if err != nil {
+		t.Fatalf("Parse(%q): %v", src, err)
+	}
+	g, err := newGraph(exprID, tree)
+	if err != nil {
+		t.Fatalf("newGraph(%q): %v", src, err)
+	}
+	tp.exprs[exprID] = g
+	tp.queue = append(tp.queue, g.pending()...)
+	return g
+}
+
+// lease1 - выдает аренду на первую задачу очереди
+func lease1(tp *TaskPuller, token string, now time.Time) *task {
+	tsk := tp.queue[0]
+	tp.queue = tp.queue[1:]
+	tp.grant(token, tsk, nil, now)
+	return tsk
+}
+
+func TestHandleResultNonFinite(t *testing.T) {
+	tests := []struct {
+		name   string
+		src    string
+		result float64
+		reason string
+	}{
+		{name: "unary function", src: "sqrt(2) + 1", result: math.Inf(1), reason: "overflow: pos 0: sqrt(2) is out of range"},
+		{name: "variadic function", src: "1 + max(1, 2, 3)", result: math.NaN(), reason: "domain_error: pos 4: max(1, 2, 3) is not a real number"},
+		{name: "binary operator", src: "2 ^ 3", result: math.Inf(-1), reason: "overflow: 2 ^ 3 is out of range"},
+		{name: "not a number", src: "2 ^ 3", result: math.NaN(), reason: "domain_error: 2 ^ 3 is not a real number"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := newFakeStorage()
+			tp := newTestPuller(st)
+			addExpr(t, tp, 1, tt.src)
+			tsk := lease1(tp, "l1", time.Now())
+
+			err := tp.handleResult(context.Background(), tp.Log, &daecv1.ResultRequest{
+				Result:     tt.result,
+				ExprId:     1,
+				NodeId:     tsk.id,
+				Attempt:    1,
+				LeaseToken: "l1",
+			})
+			if err != nil {
+				t.Fatalf("handleResult: %v", err)
+			}
+			if got := st.reasons[1]; got != tt.reason {
+				t.Errorf("reason = %q, want %q", got, tt.reason)
+			}
+			if _, ok := tp.exprs[1]; ok {
+				t.Error("failed expression is still in flight")
+			}
+		})
+	}
+}
+
+func TestHandleResultErrorPos(t *testing.T) {
+	const src = "1 + sqrt(1 - 5)"
+	tree, err := expr.Parse(src)
+	if err != nil {
+		t.Fatalf("Parse(%q): %v", src, err)
+	}
+	// граф строится, как у оркестратора, из сохраненной обратной польской записи
+	polish, err := expr.Postfix(tree)
+	if err != nil {
+		t.Fatalf("Postfix(%q): %v", src, err)
+	}
+	if tree, err = expr.ParsePostfix(polish); err != nil {
+		t.Fatalf("ParsePostfix(%q): %v", polish, err)
+	}
+	g, err := newGraph(1, tree)
+	if err != nil {
+		t.Fatalf("newGraph(%q): %v", src, err)
+	}
+
+	// позиция вызова переживает сохранение задач и перезапуск
+	if g, err = restoreGraph(1, g.models()); err != nil {
+		t.Fatalf("restoreGraph: %v", err)
+	}
+	st := newFakeStorage()
+	tp := newTestPuller(st)
+	tp.exprs[1] = g
+	tp.queue = g.pending()
+
+	sub := lease1(tp, "l1", time.Now())
+	err = tp.handleResult(context.Background(), tp.Log, &daecv1.ResultRequest{
+		Result: -4, ExprId: 1, NodeId: sub.id, Attempt: 1, LeaseToken: "l1",
+	})
+	if err != nil {
+		t.Fatalf("handleResult(1 - 5): %v", err)
+	}
+	sqrt := lease1(tp, "l2", time.Now())
+	err = tp.handleResult(context.Background(), tp.Log, &daecv1.ResultRequest{
+		Error:  &daecv1.TaskError{Code: "domain_error", Message: "sqrt(-4): argument must be non-negative"},
+		ExprId: 1, NodeId: sqrt.id, Attempt: 1, LeaseToken: "l2",
+	})
+	if err != nil {
+		t.Fatalf("handleResult(sqrt): %v", err)
+	}
+
+	want := "domain_error: pos 4: sqrt(-4): argument must be non-negative"
+	if got := st.reasons[1]; got != want {
+		t.Errorf("reason = %q, want %q", got, want)
+	}
+	if got := st.tasks[1][sqrt.id].Pos; got != 4 {
+		t.Errorf("saved task pos = %d, want 4", got)
+	}
+}
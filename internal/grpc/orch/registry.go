@@ -0,0 +1,134 @@
+package orch
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"sort"
+	"time"
+
+	daecv1 "github.com/kms-qwe/DAEC/internal/protos/gen/go/daec"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	agentAlive        = "alive"
+	agentDead         = "dead"
+	agentDisconnected = "disconnected"
+)
+
+// agentState - запись реестра агентов. Запись остается и после отключения
+// агента, при повторном подключении с тем же id она обновляется
+type agentState struct {
+	id          string
+	hostname    string
+	operations  []string
+	slots       int
+	status      string
+	session     *Session
+	connectedAt time.Time
+	lastSeen    time.Time
+	done        int64
+	failed      int64
+}
+
+// supports - умеет ли агент операцию; пустой список операций - умеет любые
+func (a *agentState) supports(op string) bool {
+	return len(a.operations) == 0 || slices.Contains(a.operations, op)
+}
+
+// register - регистрирует агента новой сессии; прежняя сессия агента с тем же id закрывается
+func (t *TaskPuller) register(ctx context.Context, log *slog.Logger, sess *Session, hello *daecv1.Hello, now time.Time) {
+	a, ok := t.agents[hello.GetAgentId()]
+	if !ok {
+		a = &agentState{id: hello.GetAgentId()}
+		t.agents[a.id] = a
+	}
+	if a.session != nil {
+		log.Info("агент подключился повторно, прежняя сессия закрыта", slog.String("agent", a.id))
+		t.dropSession(ctx, log, a.session, agentDisconnected)
+	}
+
+	a.hostname = hello.GetHostname()
+	a.operations = hello.GetOperations()
+	a.slots = int(hello.GetSlots())
+	a.status = agentAlive
+	a.session = sess
+	a.connectedAt = now
+	a.lastSeen = now
+	sess.agent = a
+}
+
+// reap - закрывает сессии агентов, от которых дольше AgentTimeout не было heartbeat
+func (t *TaskPuller) reap(ctx context.Context, log *slog.Logger, now time.Time) {
+	for _, sess := range slices.Clone(t.sessions) {
+		a := sess.agent
+		if now.Sub(a.lastSeen) <= t.AgentTimeout {
+			continue
+		}
+		log.Info(
+			"агент не присылает heartbeat, считаем его мертвым",
+			slog.String("agent", a.id),
+			slog.Time("last_seen", a.lastSeen),
+		)
+		t.dropSession(ctx, log, sess, agentDead)
+	}
+}
+
+// agentInfos - снимок реестра для ListAgents
+func (t *TaskPuller) agentInfos() []*daecv1.AgentInfo {
+	inFlight := map[*Session]int32{}
+	for _, l := range t.leases {
+		if l.session != nil && !l.expired && !l.task.done {
+			inFlight[l.session]++
+		}
+	}
+
+	res := make([]*daecv1.AgentInfo, 0, len(t.agents))
+	for _, a := range t.agents {
+		info := &daecv1.AgentInfo{
+			AgentId:             a.id,
+			Hostname:            a.hostname,
+			Status:              a.status,
+			Operations:          a.operations,
+			Slots:               int32(a.slots),
+			TasksDone:           a.done,
+			TasksFailed:         a.failed,
+			ConnectedAtUnixMs:   a.connectedAt.UnixMilli(),
+			LastHeartbeatUnixMs: a.lastSeen.UnixMilli(),
+		}
+		if a.session != nil {
+			info.SessionId = a.session.id
+			info.FreeSlots = int32(a.session.credit)
+			info.InFlight = inFlight[a.session]
+		}
+		res = append(res, info)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].AgentId < res[j].AgentId })
+	return res
+}
+
+type AdminApi struct {
+	daecv1.UnimplementedAdminServiceServer
+	TaskPull *TaskPuller
+}
+
+// ListAgents - состояние всех известных оркестратору агентов
+func (s *AdminApi) ListAgents(ctx context.Context, req *daecv1.ListAgentsRequest) (*daecv1.ListAgentsResponse, error) {
+	reply := make(chan []*daecv1.AgentInfo, 1)
+	select {
+	case s.TaskPull.ChAdmin <- reply:
+	case <-s.TaskPull.Stopped:
+		return nil, status.Error(codes.Unavailable, ErrStopped.Error())
+	case <-ctx.Done():
+		return nil, status.FromContextError(ctx.Err()).Err()
+	}
+
+	select {
+	case agents := <-reply:
+		return &daecv1.ListAgentsResponse{Agents: agents}, nil
+	case <-ctx.Done():
+		return nil, status.FromContextError(ctx.Err()).Err()
+	}
+}
@@ -0,0 +1,313 @@
+package orch
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/kms-qwe/DAEC/internal/lib/logger/sl"
+	daecv1 "github.com/kms-qwe/DAEC/internal/protos/gen/go/daec"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// maxSessionSlots - больше задач одна сессия одновременно не получает
+const maxSessionSlots = 64
+
+// Session - потоковое подключение агента. Поля меняет только цикл Eval
+type Session struct {
+	id    string
+	agent *agentState
+	// credit - сколько задач агент готов принять
+	credit int
+	// out - задачи, выданные сессии, но еще не отправленные в поток;
+	// credit + len(out) не превышает cap(out), поэтому Eval не блокируется
+	out chan *daecv1.TaskResponse
+	// kill закрывается, когда оркестратор сам завершает сессию
+	kill   chan struct{}
+	closed bool
+}
+
+type SessionEventKind int
+
+const (
+	SessionOpen SessionEventKind = iota
+	SessionReady
+	SessionHeartbeat
+	SessionClose
+)
+
+// SessionEvent - открытие и закрытие сессии агента, освободившиеся места, heartbeat
+type SessionEvent struct {
+	Session *Session
+	Kind    SessionEventKind
+	Slots   int
+	// Hello - регистрация агента, только для SessionOpen
+	Hello *daecv1.Hello
+}
+
+func newSession() *Session {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		panic("can't generate session id: " + err.Error())
+	}
+	return &Session{
+		id:   hex.EncodeToString(b),
+		out:  make(chan *daecv1.TaskResponse, maxSessionSlots),
+		kill: make(chan struct{}),
+	}
+}
+
+// Connect - сессия агента: задачи отправляются по мере готовности в пределах
+// свободных мест агента, результаты принимаются из того же потока
+func (s *ServerApi) Connect(stream daecv1.OrchService_ConnectServer) error {
+	const op = "orch.Connect"
+	ctx := stream.Context()
+
+	first, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	hello := first.GetHello()
+	if hello == nil {
+		return status.Error(codes.InvalidArgument, "first message must be hello")
+	}
+	if hello.GetAgentId() == "" {
+		return status.Error(codes.InvalidArgument, "agent_id is required")
+	}
+
+	sess := newSession()
+	log := s.TaskPull.Log.With(
+		slog.String("op", op),
+		slog.String("session", sess.id),
+		slog.String("agent", hello.GetAgentId()),
+	)
+
+	if err := s.TaskPull.sessionEvent(ctx, SessionEvent{Session: sess, Kind: SessionOpen, Slots: int(hello.GetSlots()), Hello: hello}); err != nil {
+		return err
+	}
+	defer func() {
+		// цикл Eval читает события сессий, пока не остановлен
+		select {
+		case s.TaskPull.ChSession <- SessionEvent{Session: sess, Kind: SessionClose}:
+		case <-s.TaskPull.Stopped:
+		}
+		log.Info("сессия агента закрыта")
+	}()
+	log.Info("сессия агента открыта", slog.Int("slots", int(hello.GetSlots())))
+
+	welcome := &daecv1.OrchMessage{Msg: &daecv1.OrchMessage_Welcome{Welcome: &daecv1.Welcome{SessionId: sess.id}}}
+	if err := stream.Send(welcome); err != nil {
+		return err
+	}
+
+	// Send вызывается только из этой горутины, Recv - только из recv
+	acks := make(chan *daecv1.ResultAck)
+	recvErr := make(chan error, 1)
+	go func() {
+		recvErr <- s.recv(ctx, stream, sess, acks)
+	}()
+
+	for {
+		var msg *daecv1.OrchMessage
+		select {
+		case tsk := <-sess.out:
+			msg = &daecv1.OrchMessage{Msg: &daecv1.OrchMessage_Task{Task: tsk}}
+		case ack := <-acks:
+			msg = &daecv1.OrchMessage{Msg: &daecv1.OrchMessage_Ack{Ack: ack}}
+		case err := <-recvErr:
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			log.Info("ошибка чтения из потока агента", sl.Err(err))
+			return err
+		case <-sess.kill:
+			return status.Error(codes.Unavailable, "session closed by orchestrator")
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if err := stream.Send(msg); err != nil {
+			log.Info("ошибка отправки в поток агента", sl.Err(err))
+			return err
+		}
+	}
+}
+
+// recv - читает сообщения агента: свободные места и результаты
+func (s *ServerApi) recv(ctx context.Context, stream daecv1.OrchService_ConnectServer, sess *Session, acks chan<- *daecv1.ResultAck) error {
+	for {
+		msg, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+
+		switch m := msg.GetMsg().(type) {
+		case *daecv1.AgentMessage_Ready:
+			if err := s.TaskPull.sessionEvent(ctx, SessionEvent{Session: sess, Kind: SessionReady, Slots: int(m.Ready.GetSlots())}); err != nil {
+				return err
+			}
+		case *daecv1.AgentMessage_Heartbeat:
+			if err := s.TaskPull.sessionEvent(ctx, SessionEvent{Session: sess, Kind: SessionHeartbeat}); err != nil {
+				return err
+			}
+		case *daecv1.AgentMessage_Result:
+			ack := &daecv1.ResultAck{LeaseToken: m.Result.GetLeaseToken()}
+			if err := s.TaskPull.submit(ctx, m.Result); err != nil {
+				ack.Code, ack.Message = resultCode(err), err.Error()
+			}
+			select {
+			case acks <- ack:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		default:
+			return status.Error(codes.InvalidArgument, "unexpected message")
+		}
+	}
+}
+
+func (t *TaskPuller) sessionEvent(ctx context.Context, ev SessionEvent) error {
+	select {
+	case t.ChSession <- ev:
+		return nil
+	case <-t.Stopped:
+		return status.Error(codes.Unavailable, ErrStopped.Error())
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// handleSession - учитывает событие сессии в цикле Eval
+func (t *TaskPuller) handleSession(ctx context.Context, log *slog.Logger, ev SessionEvent, now time.Time) {
+	sess := ev.Session
+	switch ev.Kind {
+	case SessionOpen:
+		t.register(ctx, log, sess, ev.Hello, now)
+		t.sessions = append(t.sessions, sess)
+		sess.addCredit(ev.Slots)
+	case SessionReady:
+		sess.addCredit(ev.Slots)
+		sess.agent.lastSeen = now
+	case SessionHeartbeat:
+		sess.agent.lastSeen = now
+	case SessionClose:
+		t.dropSession(ctx, log, sess, agentDisconnected)
+	}
+}
+
+// dropSession - убирает сессию из раздачи и возвращает ее задачи в очередь
+func (t *TaskPuller) dropSession(ctx context.Context, log *slog.Logger, sess *Session, agentStatus string) {
+	if sess.closed {
+		return
+	}
+	sess.closed = true
+	close(sess.kill)
+
+	for i, s := range t.sessions {
+		if s == sess {
+			t.sessions = append(t.sessions[:i], t.sessions[i+1:]...)
+			break
+		}
+	}
+	sess.credit = 0
+	if a := sess.agent; a.session == sess {
+		a.session = nil
+		a.status = agentStatus
+	}
+	t.revoke(ctx, log, "агент отключился, задача возвращена в очередь", func(l *lease) bool {
+		return l.session == sess
+	})
+}
+
+// closeSessions - при остановке закрывает все сессии, не отзывая их аренды:
+// в бд задачи остаются выданными, и после перезапуска агенты дошлют результаты
+func (t *TaskPuller) closeSessions(log *slog.Logger) {
+	for _, sess := range t.sessions {
+		sess.closed = true
+		close(sess.kill)
+		log.Info("сессия агента закрыта при остановке", slog.String("agent", sess.agent.id))
+	}
+	t.sessions = nil
+}
+
+func (sess *Session) addCredit(n int) {
+	if n <= 0 {
+		return
+	}
+	sess.credit = min(sess.credit+n, cap(sess.out)-len(sess.out))
+}
+
+// dispatch - раздает готовые задачи по кругу сессиям, у которых есть свободное
+// место и которые умеют операцию задачи. Остальные задачи остаются в очереди
+func (t *TaskPuller) dispatch(ctx context.Context, log *slog.Logger, now time.Time) {
+	if len(t.sessions) == 0 {
+		return
+	}
+	var sent []*task
+	rest := t.queue[:0]
+	for i, tsk := range t.queue {
+		if tsk.done || tsk.g.failed {
+			continue
+		}
+		sess := t.nextSession(tsk.op)
+		if sess == nil {
+			rest = append(rest, tsk)
+			if !t.hasCredit() {
+				rest = append(rest, t.queue[i+1:]...)
+				break
+			}
+			continue
+		}
+
+		resp := tsk.toResponse(newLeaseToken())
+		l := t.grant(resp.LeaseToken, tsk, sess, now)
+		sent = append(sent, tsk)
+		sess.credit--
+		sess.out <- resp
+		log.Info(
+			"отправлено в сессию агента",
+			slog.String("agent", sess.agent.id),
+			slog.Any("task", resp),
+			slog.Time("deadline", l.deadline),
+		)
+	}
+	t.queue = rest
+	t.persist(ctx, log, sent...)
+}
+
+// nextSession - следующая по кругу сессия со свободным местом, умеющая операцию op
+func (t *TaskPuller) nextSession(op string) *Session {
+	for range t.sessions {
+		t.rr = (t.rr + 1) % len(t.sessions)
+		if sess := t.sessions[t.rr]; sess.credit > 0 && sess.agent.supports(op) {
+			return sess
+		}
+	}
+	return nil
+}
+
+func (t *TaskPuller) hasCredit() bool {
+	for _, sess := range t.sessions {
+		if sess.credit > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// resultCode - код ответа агенту на отклоненный результат
+func resultCode(err error) string {
+	switch {
+	case errors.Is(err, ErrUnknownLease):
+		return "not_found"
+	case errors.Is(err, ErrLeaseMismatch):
+		return "failed_precondition"
+	case errors.Is(err, ErrStopped):
+		return "unavailable"
+	}
+	return "internal"
+}
@@ -1,14 +1,208 @@
 package jwt
 
 import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
 	"time"
 
+	gojwt "github.com/golang-jwt/jwt/v5"
 	"github.com/kms-qwe/DAEC/internal/domain/models"
 )
 
-func NewToken(
-	user models.User,
-	duration time.Duration,
-) (string, error) {
-	panic("implement NewToken")
+var (
+	ErrUnknownKey = errors.New("unknown signing key")
+	ErrInvalid    = errors.New("invalid token")
+)
+
+// Claims - содержимое токена доступа
+type Claims struct {
+	Login string `json:"login"`
+	UID   int64  `json:"id"`
+	gojwt.RegisteredClaims
+}
+
+// Key - ключ подписи или проверки токенов; ключ без приватной части
+// (только public_key_file) используется лишь для проверки
+type Key struct {
+	ID        string
+	Method    gojwt.SigningMethod
+	signKey   any
+	verifyKey any
+}
+
+// LoadKey - создает ключ из секрета (HS*) или PEM-файлов (RS*, EdDSA)
+func LoadKey(kid, alg, secret, privateKeyFile, publicKeyFile string) (*Key, error) {
+	if kid == "" {
+		return nil, fmt.Errorf("key without kid")
+	}
+	method := gojwt.GetSigningMethod(alg)
+	if method == nil {
+		return nil, fmt.Errorf("key %q: unsupported algorithm %q", kid, alg)
+	}
+	key := &Key{ID: kid, Method: method}
+
+	switch method.(type) {
+	case *gojwt.SigningMethodHMAC:
+		if secret == "" {
+			return nil, fmt.Errorf("key %q: empty secret", kid)
+		}
+		key.signKey, key.verifyKey = []byte(secret), []byte(secret)
+	case *gojwt.SigningMethodRSA, *gojwt.SigningMethodEd25519:
+		if err := key.loadPEM(privateKeyFile, publicKeyFile); err != nil {
+			return nil, fmt.Errorf("key %q: %w", kid, err)
+		}
+	default:
+		return nil, fmt.Errorf("key %q: unsupported algorithm %q", kid, alg)
+	}
+
+	return key, nil
+}
+
+func (k *Key) loadPEM(privateKeyFile, publicKeyFile string) error {
+	_, isRSA := k.Method.(*gojwt.SigningMethodRSA)
+
+	if privateKeyFile != "" {
+		data, err := os.ReadFile(privateKeyFile)
+		if err != nil {
+			return fmt.Errorf("can't read private key: %w", err)
+		}
+		if isRSA {
+			priv, err := gojwt.ParseRSAPrivateKeyFromPEM(data)
+			if err != nil {
+				return fmt.Errorf("can't parse private key: %w", err)
+			}
+			k.signKey, k.verifyKey = priv, &priv.PublicKey
+			return nil
+		}
+		priv, err := gojwt.ParseEdPrivateKeyFromPEM(data)
+		if err != nil {
+			return fmt.Errorf("can't parse private key: %w", err)
+		}
+		k.signKey, k.verifyKey = priv, priv.(ed25519.PrivateKey).Public()
+		return nil
+	}
+
+	if publicKeyFile == "" {
+		return fmt.Errorf("neither private_key_file nor public_key_file is set")
+	}
+	data, err := os.ReadFile(publicKeyFile)
+	if err != nil {
+		return fmt.Errorf("can't read public key: %w", err)
+	}
+	if isRSA {
+		k.verifyKey, err = gojwt.ParseRSAPublicKeyFromPEM(data)
+	} else {
+		k.verifyKey, err = gojwt.ParseEdPublicKeyFromPEM(data)
+	}
+	if err != nil {
+		return fmt.Errorf("can't parse public key: %w", err)
+	}
+	return nil
+}
+
+// CanSign - есть ли у ключа приватная часть
+func (k *Key) CanSign() bool {
+	return k.signKey != nil
+}
+
+// KeySet - активный ключ подписи и все ключи, которыми еще проверяются токены.
+// При ротации новый ключ становится активным, а старый остается в наборе,
+// пока не истекут выданные им токены
+type KeySet struct {
+	signing *Key
+	keys    map[string]*Key
+	order   []string
+}
+
+func NewKeySet(signingKID string, keys ...*Key) (*KeySet, error) {
+	ks := &KeySet{keys: map[string]*Key{}}
+	for _, k := range keys {
+		if _, ok := ks.keys[k.ID]; ok {
+			return nil, fmt.Errorf("duplicate kid %q", k.ID)
+		}
+		ks.keys[k.ID] = k
+		ks.order = append(ks.order, k.ID)
+	}
+
+	signing, ok := ks.keys[signingKID]
+	if !ok {
+		return nil, fmt.Errorf("signing key %q: %w", signingKID, ErrUnknownKey)
+	}
+	if !signing.CanSign() {
+		return nil, fmt.Errorf("signing key %q has no private key", signingKID)
+	}
+	ks.signing = signing
+
+	return ks, nil
+}
+
+// NewToken - выпускает токен доступа пользователя, подписанный активным ключом
+func (ks *KeySet) NewToken(user models.User, duration time.Duration) (string, error) {
+	now := time.Now()
+	claims := Claims{
+		Login: user.Login,
+		UID:   user.ID,
+		RegisteredClaims: gojwt.RegisteredClaims{
+			NotBefore: gojwt.NewNumericDate(now),
+			ExpiresAt: gojwt.NewNumericDate(now.Add(duration)),
+			IssuedAt:  gojwt.NewNumericDate(now),
+			ID:        newJTI(),
+		},
+	}
+
+	token := gojwt.NewWithClaims(ks.signing.Method, claims)
+	token.Header["kid"] = ks.signing.ID
+
+	tokenString, err := token.SignedString(ks.signing.signKey)
+	if err != nil {
+		return "", fmt.Errorf("can't sign token: %w", err)
+	}
+	return tokenString, nil
+}
+
+// Parse - проверяет подпись и сроки токена; ключ выбирается по kid из заголовка,
+// алгоритм токена обязан совпадать с алгоритмом ключа
+func (ks *KeySet) Parse(tokenString string) (*Claims, error) {
+	claims := &Claims{}
+	token, err := gojwt.ParseWithClaims(tokenString, claims, func(token *gojwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, ok := ks.keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("kid %q: %w", kid, ErrUnknownKey)
+		}
+		if token.Method.Alg() != key.Method.Alg() {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return key.verifyKey, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalid, err)
+	}
+	if !token.Valid {
+		return nil, ErrInvalid
+	}
+	return claims, nil
+}
+
+// newJTI - уникальный идентификатор токена, по нему токен можно отозвать
+func newJTI() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic("can't generate jti: " + err.Error())
+	}
+	return hex.EncodeToString(b)
+}
+
+// publicKey - открытый ключ для JWKS, nil для симметричных ключей
+func (k *Key) publicKey() any {
+	switch pub := k.verifyKey.(type) {
+	case *rsa.PublicKey, ed25519.PublicKey:
+		return pub
+	}
+	return nil
 }
@@ -0,0 +1,61 @@
+package password
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// MaxLen - наибольшая длина пароля в байтах: bcrypt не хэширует пароли длиннее
+const MaxLen = 72
+
+var (
+	dummyOnce sync.Once
+	dummyHash []byte
+)
+
+// Hash - соленый bcrypt-хэш пароля для хранения в бд
+func Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("can't hash password: %w", err)
+	}
+	return string(hash), nil
+}
+
+// Verify - сверяет пароль с сохраненным значением за время, не зависящее от
+// совпадения. Значение, не похожее на bcrypt-хэш, считается паролем, сохраненным
+// до перехода на хэши: при совпадении needsUpgrade = true, и его нужно перехэшировать
+func Verify(stored, password string) (ok bool, needsUpgrade bool, err error) {
+	if !IsHash(stored) {
+		ok = subtle.ConstantTimeCompare([]byte(stored), []byte(password)) == 1
+		return ok, ok, nil
+	}
+
+	err = bcrypt.CompareHashAndPassword([]byte(stored), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return false, false, nil
+	}
+	if err != nil {
+		return false, false, fmt.Errorf("can't verify password: %w", err)
+	}
+	return true, false, nil
+}
+
+// IsHash - похоже ли сохраненное значение на bcrypt-хэш
+func IsHash(stored string) bool {
+	return strings.HasPrefix(stored, "$2a$") || strings.HasPrefix(stored, "$2b$") || strings.HasPrefix(stored, "$2y$")
+}
+
+// VerifyDummy - сверяет пароль с заранее посчитанным хэшем и отбрасывает результат.
+// Вызывается, когда логин не найден, чтобы время ответа не выдавало существующие логины
+func VerifyDummy(password string) {
+	dummyOnce.Do(func() {
+		dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy password"), bcrypt.DefaultCost)
+	})
+	_ = bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+}
@@ -0,0 +1,64 @@
+package password
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestVerifyHash(t *testing.T) {
+	hash, err := Hash("secret")
+	if err != nil {
+		t.Fatalf("Hash: %v", err)
+	}
+	if !IsHash(hash) {
+		t.Fatalf("IsHash(%q) = false", hash)
+	}
+
+	ok, needsUpgrade, err := Verify(hash, "secret")
+	if err != nil || !ok || needsUpgrade {
+		t.Errorf("Verify(hash, right) = %v, %v, %v; want true, false, nil", ok, needsUpgrade, err)
+	}
+	ok, needsUpgrade, err = Verify(hash, "wrong")
+	if err != nil || ok || needsUpgrade {
+		t.Errorf("Verify(hash, wrong) = %v, %v, %v; want false, false, nil", ok, needsUpgrade, err)
+	}
+}
+
+func TestVerifyPlaintext(t *testing.T) {
+	ok, needsUpgrade, err := Verify("secret", "secret")
+	if err != nil || !ok || !needsUpgrade {
+		t.Errorf("Verify(plain, right) = %v, %v, %v; want true, true, nil", ok, needsUpgrade, err)
+	}
+	ok, needsUpgrade, err = Verify("secret", "wrong")
+	if err != nil || ok || needsUpgrade {
+		t.Errorf("Verify(plain, wrong) = %v, %v, %v; want false, false, nil", ok, needsUpgrade, err)
+	}
+}
+
+func TestIsHash(t *testing.T) {
+	tests := []struct {
+		stored string
+		want   bool
+	}{
+		{"$2a$10$abcdefghijklmnopqrstuv", true},
+		{"$2b$10$abcdefghijklmnopqrstuv", true},
+		{"$2y$10$abcdefghijklmnopqrstuv", true},
+		{"secret", false},
+		{"$2x$10$abcdefghijklmnopqrstuv", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := IsHash(tt.stored); got != tt.want {
+			t.Errorf("IsHash(%q) = %v, want %v", tt.stored, got, tt.want)
+		}
+	}
+}
+
+func TestHashTooLong(t *testing.T) {
+	if _, err := Hash(strings.Repeat("a", MaxLen)); err != nil {
+		t.Errorf("Hash(%d bytes): %v", MaxLen, err)
+	}
+	if _, err := Hash(strings.Repeat("a", MaxLen+1)); err == nil {
+		t.Errorf("Hash(%d bytes): want error", MaxLen+1)
+	}
+}
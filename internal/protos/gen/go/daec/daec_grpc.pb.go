@@ -1,6 +1,6 @@
 // Code generated by protoc-gen-go-grpc. DO NOT EDIT.
 // versions:
-// - protoc-gen-go-grpc v1.2.0
+// - protoc-gen-go-grpc v1.4.0
 // - protoc             v3.21.12
 // source: daec/daec.proto
 
@@ -15,8 +15,14 @@ import (
 
 // This is a compile-time assertion to ensure that this generated file
 // is compatible with the grpc package it is being compiled against.
-// Requires gRPC-Go v1.32.0 or later.
-const _ = grpc.SupportPackageIsVersion7
+// Requires gRPC-Go v1.62.0 or later.
+const _ = grpc.SupportPackageIsVersion8
+
+const (
+	OrchService_GiveTask_FullMethodName  = "/orch.OrchService/GiveTask"
+	OrchService_GetResult_FullMethodName = "/orch.OrchService/GetResult"
+	OrchService_Connect_FullMethodName   = "/orch.OrchService/Connect"
+)
 
 // OrchServiceClient is the client API for OrchService service.
 //
@@ -24,6 +30,11 @@ const _ = grpc.SupportPackageIsVersion7
 type OrchServiceClient interface {
 	GiveTask(ctx context.Context, in *TaskRequest, opts ...grpc.CallOption) (*TaskResponse, error)
 	GetResult(ctx context.Context, in *ResultRequest, opts ...grpc.CallOption) (*ResultResponse, error)
+	// Connect - сессия агента: агент сообщает о свободных местах (Ready),
+	// оркестратор присылает задачи по мере готовности, агент возвращает
+	// результаты в тот же поток. Первым сообщением агент отправляет Hello.
+	// При закрытии потока задачи агента возвращаются в очередь.
+	Connect(ctx context.Context, opts ...grpc.CallOption) (OrchService_ConnectClient, error)
 }
 
 type orchServiceClient struct {
@@ -35,8 +46,9 @@ func NewOrchServiceClient(cc grpc.ClientConnInterface) OrchServiceClient {
 }
 
 func (c *orchServiceClient) GiveTask(ctx context.Context, in *TaskRequest, opts ...grpc.CallOption) (*TaskResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(TaskResponse)
-	err := c.cc.Invoke(ctx, "/orch.OrchService/GiveTask", in, out, opts...)
+	err := c.cc.Invoke(ctx, OrchService_GiveTask_FullMethodName, in, out, cOpts...)
 	if err != nil {
 		return nil, err
 	}
@@ -44,20 +56,58 @@ func (c *orchServiceClient) GiveTask(ctx context.Context, in *TaskRequest, opts
 }
 
 func (c *orchServiceClient) GetResult(ctx context.Context, in *ResultRequest, opts ...grpc.CallOption) (*ResultResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(ResultResponse)
-	err := c.cc.Invoke(ctx, "/orch.OrchService/GetResult", in, out, opts...)
+	err := c.cc.Invoke(ctx, OrchService_GetResult_FullMethodName, in, out, cOpts...)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
+func (c *orchServiceClient) Connect(ctx context.Context, opts ...grpc.CallOption) (OrchService_ConnectClient, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &OrchService_ServiceDesc.Streams[0], OrchService_Connect_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &orchServiceConnectClient{ClientStream: stream}
+	return x, nil
+}
+
+type OrchService_ConnectClient interface {
+	Send(*AgentMessage) error
+	Recv() (*OrchMessage, error)
+	grpc.ClientStream
+}
+
+type orchServiceConnectClient struct {
+	grpc.ClientStream
+}
+
+func (x *orchServiceConnectClient) Send(m *AgentMessage) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *orchServiceConnectClient) Recv() (*OrchMessage, error) {
+	m := new(OrchMessage)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // OrchServiceServer is the server API for OrchService service.
 // All implementations must embed UnimplementedOrchServiceServer
 // for forward compatibility
 type OrchServiceServer interface {
 	GiveTask(context.Context, *TaskRequest) (*TaskResponse, error)
 	GetResult(context.Context, *ResultRequest) (*ResultResponse, error)
+	// Connect - сессия агента: агент сообщает о свободных местах (Ready),
+	// оркестратор присылает задачи по мере готовности, агент возвращает
+	// результаты в тот же поток. Первым сообщением агент отправляет Hello.
+	// При закрытии потока задачи агента возвращаются в очередь.
+	Connect(OrchService_ConnectServer) error
 	mustEmbedUnimplementedOrchServiceServer()
 }
 
@@ -71,6 +121,9 @@ func (UnimplementedOrchServiceServer) GiveTask(context.Context, *TaskRequest) (*
 func (UnimplementedOrchServiceServer) GetResult(context.Context, *ResultRequest) (*ResultResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetResult not implemented")
 }
+func (UnimplementedOrchServiceServer) Connect(OrchService_ConnectServer) error {
+	return status.Errorf(codes.Unimplemented, "method Connect not implemented")
+}
 func (UnimplementedOrchServiceServer) mustEmbedUnimplementedOrchServiceServer() {}
 
 // UnsafeOrchServiceServer may be embedded to opt out of forward compatibility for this service.
@@ -94,7 +147,7 @@ func _OrchService_GiveTask_Handler(srv interface{}, ctx context.Context, dec fun
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/orch.OrchService/GiveTask",
+		FullMethod: OrchService_GiveTask_FullMethodName,
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
 		return srv.(OrchServiceServer).GiveTask(ctx, req.(*TaskRequest))
@@ -112,7 +165,7 @@ func _OrchService_GetResult_Handler(srv interface{}, ctx context.Context, dec fu
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/orch.OrchService/GetResult",
+		FullMethod: OrchService_GetResult_FullMethodName,
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
 		return srv.(OrchServiceServer).GetResult(ctx, req.(*ResultRequest))
@@ -120,6 +173,32 @@ func _OrchService_GetResult_Handler(srv interface{}, ctx context.Context, dec fu
 	return interceptor(ctx, in, info, handler)
 }
 
+func _OrchService_Connect_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(OrchServiceServer).Connect(&orchServiceConnectServer{ServerStream: stream})
+}
+
+type OrchService_ConnectServer interface {
+	Send(*OrchMessage) error
+	Recv() (*AgentMessage, error)
+	grpc.ServerStream
+}
+
+type orchServiceConnectServer struct {
+	grpc.ServerStream
+}
+
+func (x *orchServiceConnectServer) Send(m *OrchMessage) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *orchServiceConnectServer) Recv() (*AgentMessage, error) {
+	m := new(AgentMessage)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // OrchService_ServiceDesc is the grpc.ServiceDesc for OrchService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -136,6 +215,108 @@ var OrchService_ServiceDesc = grpc.ServiceDesc{
 			Handler:    _OrchService_GetResult_Handler,
 		},
 	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Connect",
+			Handler:       _OrchService_Connect_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "daec/daec.proto",
+}
+
+const (
+	AdminService_ListAgents_FullMethodName = "/orch.AdminService/ListAgents"
+)
+
+// AdminServiceClient is the client API for AdminService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// AdminService - служебные методы оркестратора.
+type AdminServiceClient interface {
+	ListAgents(ctx context.Context, in *ListAgentsRequest, opts ...grpc.CallOption) (*ListAgentsResponse, error)
+}
+
+type adminServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewAdminServiceClient(cc grpc.ClientConnInterface) AdminServiceClient {
+	return &adminServiceClient{cc}
+}
+
+func (c *adminServiceClient) ListAgents(ctx context.Context, in *ListAgentsRequest, opts ...grpc.CallOption) (*ListAgentsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListAgentsResponse)
+	err := c.cc.Invoke(ctx, AdminService_ListAgents_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// AdminServiceServer is the server API for AdminService service.
+// All implementations must embed UnimplementedAdminServiceServer
+// for forward compatibility
+//
+// AdminService - служебные методы оркестратора.
+type AdminServiceServer interface {
+	ListAgents(context.Context, *ListAgentsRequest) (*ListAgentsResponse, error)
+	mustEmbedUnimplementedAdminServiceServer()
+}
+
+// UnimplementedAdminServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedAdminServiceServer struct {
+}
+
+func (UnimplementedAdminServiceServer) ListAgents(context.Context, *ListAgentsRequest) (*ListAgentsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListAgents not implemented")
+}
+func (UnimplementedAdminServiceServer) mustEmbedUnimplementedAdminServiceServer() {}
+
+// UnsafeAdminServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to AdminServiceServer will
+// result in compilation errors.
+type UnsafeAdminServiceServer interface {
+	mustEmbedUnimplementedAdminServiceServer()
+}
+
+func RegisterAdminServiceServer(s grpc.ServiceRegistrar, srv AdminServiceServer) {
+	s.RegisterService(&AdminService_ServiceDesc, srv)
+}
+
+func _AdminService_ListAgents_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListAgentsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).ListAgents(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AdminService_ListAgents_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).ListAgents(ctx, req.(*ListAgentsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// AdminService_ServiceDesc is the grpc.ServiceDesc for AdminService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var AdminService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "orch.AdminService",
+	HandlerType: (*AdminServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListAgents",
+			Handler:    _AdminService_ListAgents_Handler,
+		},
+	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "daec/daec.proto",
 }
@@ -3,10 +3,15 @@ package sqlite
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"strconv"
+	"strings"
+	"time"
 
 	"github.com/kms-qwe/DAEC/internal/app/auth"
+	"github.com/kms-qwe/DAEC/internal/domain/models"
+	"github.com/kms-qwe/DAEC/internal/storage"
 	_ "github.com/mattn/go-sqlite3"
 )
 
@@ -25,56 +30,267 @@ func NewOrchStorage(path string) (*OrchStorage, error) {
 	return &OrchStorage{db: db}, nil
 }
 
-func (s *OrchStorage) GetExpr(ctx context.Context) (int64, string, error) {
-	q := `SELECT expr_id, polish_expr FROM expressions WHERE status = "computing" LIMIT 1`
+// GetExprs - до limit невычисленных выражений, кроме уже взятых в работу (exclude), старые первыми
+func (s *OrchStorage) GetExprs(ctx context.Context, limit int, exclude []int64) ([]models.Expr, error) {
+	q := `SELECT expr_id, polish_expr FROM expressions WHERE status = "computing"`
+	args := make([]any, 0, len(exclude)+1)
+	if len(exclude) > 0 {
+		q += ` AND expr_id NOT IN (?` + strings.Repeat(`, ?`, len(exclude)-1) + `)`
+		for _, id := range exclude {
+			args = append(args, id)
+		}
+	}
+	q += ` ORDER BY expr_id LIMIT ?`
+	args = append(args, limit)
 
-	var exprID int64
-	var polishExpr string
+	rows, err := s.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, fmt.Errorf("can't get computing exprs: %w", err)
+	}
+	defer rows.Close()
 
-	err := s.db.QueryRowContext(ctx, q).Scan(&exprID, &polishExpr)
-	if err == sql.ErrNoRows {
-		return 0, "", fmt.Errorf("no computing expr in db: %w", err)
+	var ans []models.Expr
+	for rows.Next() {
+		var expr models.Expr
+		if err := rows.Scan(&expr.ID, &expr.PolishExpr); err != nil {
+			return nil, fmt.Errorf("can't get computing exprs: %w", err)
+		}
+		ans = append(ans, expr)
 	}
-	if err != nil {
-		return 0, "", fmt.Errorf("can't get computing expr: %w", err)
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("can't get computing exprs: %w", err)
 	}
 
-	return exprID, polishExpr, nil
+	return ans, nil
 }
 
-func (s *OrchStorage) SaveExpr(ctx context.Context, exprID int64, expr string) error {
+// SaveResult - завершает выражение со статусом done и результатом,
+// в той же транзакции ставит в outbox вебхук выражения. Отмененное
+// или удаленное к этому времени выражение не меняется
+func (s *OrchStorage) SaveResult(ctx context.Context, exprID int64, result float64) error {
+	q := `UPDATE expressions SET status = "done", result = ? WHERE expr_id = ? AND status = "computing"`
 
-	var isNumber = func(str string) bool {
-		_, err := strconv.ParseFloat(str, 64)
-		return err == nil
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, q, result, exprID)
+		if err != nil {
+			return fmt.Errorf("can't update expr with result: %w", err)
+		}
+		if n, err := res.RowsAffected(); err != nil || n == 0 {
+			return err
+		}
+		return enqueueWebhook(ctx, tx, models.ExprEvent{
+			Event:  models.EventExprDone,
+			ID:     exprID,
+			Status: "done",
+			Result: result,
+		})
+	})
+}
+
+// GetExprStatuses - статусы выражений по id; удаленных выражений в ответе нет
+func (s *OrchStorage) GetExprStatuses(ctx context.Context, ids []int64) (map[int64]string, error) {
+	ans := make(map[int64]string, len(ids))
+	if len(ids) == 0 {
+		return ans, nil
+	}
+	q := `SELECT expr_id, status FROM expressions WHERE expr_id IN (?` + strings.Repeat(`, ?`, len(ids)-1) + `)`
+	args := make([]any, 0, len(ids))
+	for _, id := range ids {
+		args = append(args, id)
+	}
+
+	rows, err := s.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, fmt.Errorf("can't get expr statuses: %w", err)
 	}
-	var q string
+	defer rows.Close()
 
-	if isNumber(expr) {
-		q = `UPDATE expressions SET polish_expr = ?, status = "done", result = ? WHERE expr_id = ?`
+	for rows.Next() {
+		var id int64
+		var status string
+		if err := rows.Scan(&id, &status); err != nil {
+			return nil, fmt.Errorf("can't get expr statuses: %w", err)
+		}
+		ans[id] = status
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("can't get expr statuses: %w", err)
+	}
 
-		res, _ := strconv.ParseFloat(expr, 64)
+	return ans, nil
+}
 
-		_, err := s.db.ExecContext(ctx, q, expr, res, exprID)
+// SaveTasks - сохраняет задачи только что построенного графа выражения
+func (s *OrchStorage) SaveTasks(ctx context.Context, tasks []models.Task) error {
+	q := `INSERT INTO tasks (expr_id, node_id, op, pos, parent_id, arg_idx, argc, arg1, arg2, extra_args, status, agent_id, attempt, lease_token, result)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
 
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		stmt, err := tx.PrepareContext(ctx, q)
 		if err != nil {
-			return fmt.Errorf("can't update expr with result: %w", err)
+			return fmt.Errorf("can't save tasks: %w", err)
 		}
+		defer stmt.Close()
 
+		for _, t := range tasks {
+			arg1, arg2, extra, err := taskArgs(t)
+			if err != nil {
+				return fmt.Errorf("can't save task %d/%d: %w", t.ExprID, t.NodeID, err)
+			}
+			_, err = stmt.ExecContext(ctx,
+				t.ExprID, t.NodeID, t.Op, t.Pos, t.ParentID, t.ArgIdx, len(t.Args), arg1, arg2, extra,
+				t.Status, t.AgentID, t.Attempt, t.LeaseToken, t.Result,
+			)
+			if err != nil {
+				return fmt.Errorf("can't save task %d/%d: %w", t.ExprID, t.NodeID, err)
+			}
+		}
 		return nil
-	}
+	})
+}
 
-	q = `UPDATE expressions SET polish_expr = ? WHERE expr_id = ?`
+// UpdateTasks - обновляет изменяемые поля задач одной транзакцией
+func (s *OrchStorage) UpdateTasks(ctx context.Context, tasks []models.Task) error {
+	q := `UPDATE tasks SET arg1 = ?, arg2 = ?, extra_args = ?, status = ?, agent_id = ?, attempt = ?, lease_token = ?, result = ?
+	WHERE expr_id = ? AND node_id = ?`
 
-	_, err := s.db.ExecContext(ctx, q, expr, exprID)
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		stmt, err := tx.PrepareContext(ctx, q)
+		if err != nil {
+			return fmt.Errorf("can't update tasks: %w", err)
+		}
+		defer stmt.Close()
+
+		for _, t := range tasks {
+			arg1, arg2, extra, err := taskArgs(t)
+			if err != nil {
+				return fmt.Errorf("can't update task %d/%d: %w", t.ExprID, t.NodeID, err)
+			}
+			_, err = stmt.ExecContext(ctx,
+				arg1, arg2, extra, t.Status, t.AgentID, t.Attempt, t.LeaseToken, t.Result,
+				t.ExprID, t.NodeID,
+			)
+			if err != nil {
+				return fmt.Errorf("can't update task %d/%d: %w", t.ExprID, t.NodeID, err)
+			}
+		}
+		return nil
+	})
+}
+
+// GetTasks - сохраненные задачи выражения, пусто если граф еще не строился
+func (s *OrchStorage) GetTasks(ctx context.Context, exprID int64) ([]models.Task, error) {
+	q := `SELECT node_id, op, pos, parent_id, arg_idx, argc, arg1, arg2, extra_args, status, agent_id, attempt, lease_token, result
+	FROM tasks WHERE expr_id = ? ORDER BY node_id`
 
+	rows, err := s.db.QueryContext(ctx, q, exprID)
 	if err != nil {
-		return fmt.Errorf("can't update expr: %w", err)
+		return nil, fmt.Errorf("can't get tasks: %w", err)
 	}
+	defer rows.Close()
 
+	var ans []models.Task
+	for rows.Next() {
+		t := models.Task{ExprID: exprID}
+		var argc int
+		var arg1, arg2, result sql.NullFloat64
+		var extra string
+		err := rows.Scan(
+			&t.NodeID, &t.Op, &t.Pos, &t.ParentID, &t.ArgIdx, &argc, &arg1, &arg2, &extra,
+			&t.Status, &t.AgentID, &t.Attempt, &t.LeaseToken, &result,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("can't get tasks: %w", err)
+		}
+		var rest []*float64
+		if extra != "" {
+			if err := json.Unmarshal([]byte(extra), &rest); err != nil {
+				return nil, fmt.Errorf("can't get tasks: task %d: %w", t.NodeID, err)
+			}
+		}
+		t.Args = append([]*float64{nullFloat(arg1), nullFloat(arg2)}, rest...)
+		if argc < 0 || argc > len(t.Args) {
+			return nil, fmt.Errorf("can't get tasks: task %d has %d args", t.NodeID, argc)
+		}
+		t.Args = t.Args[:argc]
+		t.Result = nullFloat(result)
+		ans = append(ans, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("can't get tasks: %w", err)
+	}
+
+	return ans, nil
+}
+
+func (s *OrchStorage) inTx(ctx context.Context, fn func(*sql.Tx) error) error {
+	return withTx(ctx, s.db, fn)
+}
+
+func withTx(ctx context.Context, db *sql.DB, fn func(*sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("can't begin transaction: %w", err)
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("can't commit transaction: %w", err)
+	}
 	return nil
 }
 
+// taskArgs - аргументы задачи для колонок arg1, arg2 и extra_args;
+// аргументы функций после второго хранятся в extra_args JSON-массивом
+func taskArgs(t models.Task) (arg1, arg2 *float64, extra string, err error) {
+	if len(t.Args) > 0 {
+		arg1 = t.Args[0]
+	}
+	if len(t.Args) > 1 {
+		arg2 = t.Args[1]
+	}
+	if len(t.Args) > 2 {
+		b, err := json.Marshal(t.Args[2:])
+		if err != nil {
+			return nil, nil, "", err
+		}
+		extra = string(b)
+	}
+	return arg1, arg2, extra, nil
+}
+
+func nullFloat(v sql.NullFloat64) *float64 {
+	if !v.Valid {
+		return nil
+	}
+	return &v.Float64
+}
+
+// FailExpr - завершает выражение со статусом error и причиной ошибки,
+// в той же транзакции ставит в outbox вебхук выражения. Отмененное
+// или удаленное к этому времени выражение не меняется
+func (s *OrchStorage) FailExpr(ctx context.Context, exprID int64, reason string) error {
+	q := `UPDATE expressions SET status = "error", reason = ? WHERE expr_id = ? AND status = "computing"`
+
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, q, reason, exprID)
+		if err != nil {
+			return fmt.Errorf("can't update expr with error: %w", err)
+		}
+		if n, err := res.RowsAffected(); err != nil || n == 0 {
+			return err
+		}
+		return enqueueWebhook(ctx, tx, models.ExprEvent{
+			Event:  models.EventExprFailed,
+			ID:     exprID,
+			Status: "error",
+			Reason: reason,
+		})
+	})
+}
+
 type AuthStorage struct {
 	db *sql.DB
 }
@@ -129,7 +345,7 @@ func (s *AuthStorage) GetPassword(ctx context.Context, login string) (string, in
 	var pass string
 	err := s.db.QueryRowContext(ctx, q, login).Scan(&userID, &pass)
 	if err == sql.ErrNoRows {
-		return "", 0, fmt.Errorf("no such user in db: %w", err)
+		return "", 0, storage.ErrUserNotFound
 	}
 	if err != nil {
 		return "", 0, fmt.Errorf("can't get password by login: %w", err)
@@ -138,41 +354,369 @@ func (s *AuthStorage) GetPassword(ctx context.Context, login string) (string, in
 	return pass, userID, nil
 }
 
+// UpdatePassword - заменяет сохраненный пароль пользователя (хэшем)
+func (s *AuthStorage) UpdatePassword(ctx context.Context, userID int64, hash string) error {
+	q := `UPDATE users SET password = ? WHERE user_id = ?`
+
+	if _, err := s.db.ExecContext(ctx, q, hash, userID); err != nil {
+		return fmt.Errorf("can't update password: %w", err)
+	}
+
+	return nil
+}
+
 func (s *AuthStorage) GetById(ctx context.Context, exprID int64, userID int64) (auth.Expr, error) {
-	q := `SELECT expr_id, expr, status, result FROM expressions WHERE expr_id = ? AND user_id = ?`
+	q := `SELECT expr_id, expr, status, result, reason, created_at, bindings FROM expressions WHERE expr_id = ? AND user_id = ?`
 
 	var ans auth.Expr
+	var created int64
+	var bindings string
 
-	err := s.db.QueryRowContext(ctx, q, exprID, userID).Scan(&ans.Id, &ans.Exp, &ans.Status, &ans.Result)
+	err := s.db.QueryRowContext(ctx, q, exprID, userID).Scan(&ans.Id, &ans.Exp, &ans.Status, &ans.Result, &ans.Reason, &created, &bindings)
 	if err == sql.ErrNoRows {
-		return auth.Expr{}, fmt.Errorf("no such expr in db: %w", err)
+		return auth.Expr{}, storage.ErrExprNotFound
 	}
 	if err != nil {
 		return auth.Expr{}, fmt.Errorf("can't get expr: %w", err)
 	}
+	ans.CreatedAt = createdAt(created)
+	if ans.Bindings, err = decodeBindings(bindings); err != nil {
+		return auth.Expr{}, fmt.Errorf("can't get expr: %w", err)
+	}
 
 	return ans, nil
 }
-func (s *AuthStorage) SaveNewExpr(ctx context.Context, userID int64, expr string, polishExpr string) (int64, error) {
-	q := `INSERT INTO expressions (expr, polish_expr, user_id) VALUES (?, ?, ?)`
 
-	result, err := s.db.ExecContext(ctx, q, expr, polishExpr, userID)
+// GetEventsCursor - с какого id начинать опрос состояний выражений пользователя:
+// самое старое вычисляемое выражение, а если таких нет - следующее за последним
+func (s *AuthStorage) GetEventsCursor(ctx context.Context, userID int64) (int64, error) {
+	q := `SELECT COALESCE(MIN(CASE WHEN status = 'computing' THEN expr_id END), MAX(expr_id) + 1, 0)
+	FROM expressions WHERE user_id = ?`
+
+	var since int64
+	if err := s.db.QueryRowContext(ctx, q, userID).Scan(&since); err != nil {
+		return 0, fmt.Errorf("can't get events cursor: %w", err)
+	}
+	return since, nil
+}
+
+// GetExprStates - выражения пользователя с id не меньше sinceID и число их вычисленных операций
+func (s *AuthStorage) GetExprStates(ctx context.Context, userID int64, sinceID int64) ([]auth.ExprState, error) {
+	q := `SELECT e.expr_id, e.status, e.result, e.reason, COUNT(t.node_id), COALESCE(SUM(t.status = 'done'), 0)
+	FROM expressions e LEFT JOIN tasks t ON t.expr_id = e.expr_id
+	WHERE e.user_id = ? AND e.expr_id >= ?
+	GROUP BY e.expr_id ORDER BY e.expr_id`
+
+	rows, err := s.db.QueryContext(ctx, q, userID, sinceID)
 	if err != nil {
-		return 0, fmt.Errorf("cant't save new expression: %w", err)
+		return nil, fmt.Errorf("can't get expr states: %w", err)
 	}
-	id, err := result.LastInsertId()
+	defer rows.Close()
+
+	var ans []auth.ExprState
+	for rows.Next() {
+		var st auth.ExprState
+		if err := rows.Scan(&st.Id, &st.Status, &st.Result, &st.Reason, &st.TasksTotal, &st.TasksDone); err != nil {
+			return nil, fmt.Errorf("can't get expr states: %w", err)
+		}
+		ans = append(ans, st)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("can't get expr states: %w", err)
+	}
+
+	return ans, nil
+}
+
+// SaveNewExpr - сохраняет выражение; непустой CallbackURL получит вебхук о его завершении
+func (s *AuthStorage) SaveNewExpr(ctx context.Context, userID int64, expr auth.NewExpr) (int64, error) {
+	ids, err := s.SaveNewExprs(ctx, userID, []auth.NewExpr{expr})
 	if err != nil {
-		return 0, fmt.Errorf("cant't save new expression: %w", err)
+		return 0, err
 	}
 
-	return id, nil
+	return ids[0], nil
+}
+
+// SaveNewExprs - сохраняет выражения одной транзакцией, id возвращаются в порядке выражений
+func (s *AuthStorage) SaveNewExprs(ctx context.Context, userID int64, exprs []auth.NewExpr) ([]int64, error) {
+	var ids []int64
+	err := withTx(ctx, s.db, func(tx *sql.Tx) error {
+		var err error
+		ids, err = insertExprs(ctx, tx, userID, 0, exprs)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return ids, nil
 }
-func (s *AuthStorage) GetAll(ctx context.Context, userID int64) ([]auth.Expr, error) {
-	q := `SELECT expr_id, expr, status, result FROM expressions WHERE user_id = ?`
+
+// SaveNewExprOnce - сохраняет выражение под ключом идемпотентности. Если ключ
+// уже использован с тем же отпечатком запроса, возвращает id сохраненного тогда
+// выражения и replayed = true; с другим отпечатком - ErrIdempotencyKeyReused.
+// Ключи старше ttl забываются
+func (s *AuthStorage) SaveNewExprOnce(ctx context.Context, userID int64, key, fingerprint string, ttl time.Duration, expr auth.NewExpr) (int64, bool, error) {
+	qClean := `DELETE FROM idempotency_keys WHERE created_at < ?`
+	qClaim := `INSERT INTO idempotency_keys (user_id, key, fingerprint, expr_id, created_at) VALUES (?, ?, ?, 0, ?)
+	ON CONFLICT (user_id, key) DO NOTHING`
+	qGet := `SELECT fingerprint, expr_id FROM idempotency_keys WHERE user_id = ? AND key = ?`
+	qSet := `UPDATE idempotency_keys SET expr_id = ? WHERE user_id = ? AND key = ?`
+
+	var id int64
+	var replayed bool
+	err := withTx(ctx, s.db, func(tx *sql.Tx) error {
+		now := time.Now()
+		if _, err := tx.ExecContext(ctx, qClean, now.Add(-ttl).UnixMilli()); err != nil {
+			return fmt.Errorf("can't clean idempotency keys: %w", err)
+		}
+
+		// ключ занимается до вставки выражения: параллельный повтор ждет
+		// завершения этой транзакции и затем видит сохраненный id
+		res, err := tx.ExecContext(ctx, qClaim, userID, key, fingerprint, now.UnixMilli())
+		if err != nil {
+			return fmt.Errorf("can't save idempotency key: %w", err)
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("can't save idempotency key: %w", err)
+		}
+		if n == 0 {
+			var saved string
+			if err := tx.QueryRowContext(ctx, qGet, userID, key).Scan(&saved, &id); err != nil {
+				return fmt.Errorf("can't get idempotency key: %w", err)
+			}
+			if saved != fingerprint {
+				return storage.ErrIdempotencyKeyReused
+			}
+			replayed = true
+			return nil
+		}
+
+		ids, err := insertExprs(ctx, tx, userID, 0, []auth.NewExpr{expr})
+		if err != nil {
+			return err
+		}
+		id = ids[0]
+		if _, err := tx.ExecContext(ctx, qSet, id, userID, key); err != nil {
+			return fmt.Errorf("can't save idempotency key: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, false, err
+	}
+
+	return id, replayed, nil
+}
+
+// insertExprs - вставляет выражения и адреса их вебхуков в транзакции tx;
+// sweepID - перебор, к которому относятся выражения, 0 - без перебора
+func insertExprs(ctx context.Context, tx *sql.Tx, userID, sweepID int64, exprs []auth.NewExpr) ([]int64, error) {
+	q := `INSERT INTO expressions (expr, polish_expr, bindings, user_id, created_at, sweep_id, status, reason)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+	qCallback := `INSERT INTO expr_callbacks (expr_id, url) VALUES (?, ?)`
+
+	stmt, err := tx.PrepareContext(ctx, q)
+	if err != nil {
+		return nil, fmt.Errorf("cant't save new expression: %w", err)
+	}
+	defer stmt.Close()
+
+	ids := make([]int64, 0, len(exprs))
+	now := time.Now().UnixMilli()
+	for _, e := range exprs {
+		bindings, err := encodeBindings(e.Bindings)
+		if err != nil {
+			return nil, fmt.Errorf("cant't save new expression: %w", err)
+		}
+		status := "computing"
+		if e.Reason != "" {
+			status = "error"
+		}
+		result, err := stmt.ExecContext(ctx, e.Expr, e.PolishExpr, bindings, userID, now, sweepID, status, e.Reason)
+		if err != nil {
+			return nil, fmt.Errorf("cant't save new expression: %w", err)
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return nil, fmt.Errorf("cant't save new expression: %w", err)
+		}
+		ids = append(ids, id)
+
+		if e.CallbackURL == "" {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, qCallback, id, e.CallbackURL); err != nil {
+			return nil, fmt.Errorf("cant't save expression callback: %w", err)
+		}
+	}
+
+	return ids, nil
+}
+
+// GetByIds - выражения пользователя из списка id, упорядоченные по id
+func (s *AuthStorage) GetByIds(ctx context.Context, ids []int64, userID int64) ([]auth.Expr, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	q := `SELECT expr_id, expr, status, result, reason, created_at, bindings FROM expressions
+	WHERE user_id = ? AND expr_id IN (?` + strings.Repeat(`, ?`, len(ids)-1) + `) ORDER BY expr_id`
+	args := make([]any, 0, len(ids)+1)
+	args = append(args, userID)
+	for _, id := range ids {
+		args = append(args, id)
+	}
+
+	rows, err := s.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, fmt.Errorf("can't get expressions: %w", err)
+	}
+	defer rows.Close()
 
 	var ans []auth.Expr
+	for rows.Next() {
+		expr := auth.Expr{}
+		var created int64
+		var bindings string
+		if err := rows.Scan(&expr.Id, &expr.Exp, &expr.Status, &expr.Result, &expr.Reason, &created, &bindings); err != nil {
+			return nil, fmt.Errorf("can't get expressions: %w", err)
+		}
+		expr.CreatedAt = createdAt(created)
+		if expr.Bindings, err = decodeBindings(bindings); err != nil {
+			return nil, fmt.Errorf("can't get expressions: %w", err)
+		}
+		ans = append(ans, expr)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("can't get expressions: %w", err)
+	}
+
+	return ans, nil
+}
+
+// CancelExpr - отменяет вычисляемое выражение пользователя и ставит в outbox его вебхук.
+// Повторная отмена не ошибка; ErrExprFinished - выражение уже вычислено или завершилось ошибкой
+func (s *AuthStorage) CancelExpr(ctx context.Context, exprID int64, userID int64) error {
+	q := `UPDATE expressions SET status = "cancelled" WHERE expr_id = ? AND user_id = ? AND status = "computing"`
+	qStatus := `SELECT status FROM expressions WHERE expr_id = ? AND user_id = ?`
+
+	return withTx(ctx, s.db, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, q, exprID, userID)
+		if err != nil {
+			return fmt.Errorf("can't cancel expr: %w", err)
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("can't cancel expr: %w", err)
+		}
+		if n == 1 {
+			return enqueueWebhook(ctx, tx, models.ExprEvent{
+				Event:  models.EventExprCancelled,
+				ID:     exprID,
+				Status: "cancelled",
+			})
+		}
 
-	rows, err := s.db.QueryContext(ctx, q, userID)
+		var status string
+		err = tx.QueryRowContext(ctx, qStatus, exprID, userID).Scan(&status)
+		if errors.Is(err, sql.ErrNoRows) {
+			return storage.ErrExprNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("can't cancel expr: %w", err)
+		}
+		if status != "cancelled" {
+			return storage.ErrExprFinished
+		}
+		return nil
+	})
+}
+
+// DeleteExpr - удаляет выражение пользователя вместе с его задачами, вебхуками
+// и ключами идемпотентности. Если выражение входит в перебор, число выражений
+// перебора уменьшается
+func (s *AuthStorage) DeleteExpr(ctx context.Context, exprID int64, userID int64) error {
+	qSweep := `SELECT sweep_id FROM expressions WHERE expr_id = ? AND user_id = ?`
+	q := `DELETE FROM expressions WHERE expr_id = ? AND user_id = ?`
+	qTotal := `UPDATE sweeps SET total = total - 1 WHERE sweep_id = ?`
+	related := []string{
+		`DELETE FROM tasks WHERE expr_id = ?`,
+		`DELETE FROM expr_callbacks WHERE expr_id = ?`,
+		`DELETE FROM webhook_attempts WHERE webhook_id IN (SELECT id FROM webhook_outbox WHERE expr_id = ?)`,
+		`DELETE FROM webhook_outbox WHERE expr_id = ?`,
+		`DELETE FROM idempotency_keys WHERE expr_id = ?`,
+	}
+
+	return withTx(ctx, s.db, func(tx *sql.Tx) error {
+		var sweepID int64
+		err := tx.QueryRowContext(ctx, qSweep, exprID, userID).Scan(&sweepID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return storage.ErrExprNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("can't delete expr: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, q, exprID, userID); err != nil {
+			return fmt.Errorf("can't delete expr: %w", err)
+		}
+		for _, q := range related {
+			if _, err := tx.ExecContext(ctx, q, exprID); err != nil {
+				return fmt.Errorf("can't delete expr: %w", err)
+			}
+		}
+		if sweepID > 0 {
+			if _, err := tx.ExecContext(ctx, qTotal, sweepID); err != nil {
+				return fmt.Errorf("can't delete expr: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// GetAll - страница выражений пользователя по фильтру, упорядоченная по id
+func (s *AuthStorage) GetAll(ctx context.Context, userID int64, f auth.ExprFilter) ([]auth.Expr, error) {
+	q := `SELECT expr_id, expr, status, result, reason, created_at, bindings FROM expressions WHERE user_id = ?`
+	args := []any{userID}
+
+	if f.AfterID > 0 {
+		if f.Desc {
+			q += ` AND expr_id < ?`
+		} else {
+			q += ` AND expr_id > ?`
+		}
+		args = append(args, f.AfterID)
+	}
+	if len(f.Statuses) > 0 {
+		q += ` AND status IN (?` + strings.Repeat(`, ?`, len(f.Statuses)-1) + `)`
+		for _, st := range f.Statuses {
+			args = append(args, st)
+		}
+	}
+	if !f.CreatedAfter.IsZero() {
+		q += ` AND created_at >= ?`
+		args = append(args, f.CreatedAfter.UnixMilli())
+	}
+	if !f.CreatedBefore.IsZero() {
+		q += ` AND created_at < ?`
+		args = append(args, f.CreatedBefore.UnixMilli())
+	}
+	if f.Search != "" {
+		q += ` AND expr LIKE ? ESCAPE '\'`
+		args = append(args, "%"+likeEscaper.Replace(f.Search)+"%")
+	}
+	if f.Desc {
+		q += ` ORDER BY expr_id DESC`
+	} else {
+		q += ` ORDER BY expr_id`
+	}
+	q += ` LIMIT ?`
+	args = append(args, f.Limit)
+
+	var ans []auth.Expr
+
+	rows, err := s.db.QueryContext(ctx, q, args...)
 	if err != nil {
 		return nil, fmt.Errorf("can't get all expressions: %w", err)
 	}
@@ -180,16 +724,147 @@ func (s *AuthStorage) GetAll(ctx context.Context, userID int64) ([]auth.Expr, er
 
 	for rows.Next() {
 		expr := auth.Expr{}
-		err := rows.Scan(&expr.Id, &expr.Exp, &expr.Status, &expr.Result)
+		var created int64
+		var bindings string
+		err := rows.Scan(&expr.Id, &expr.Exp, &expr.Status, &expr.Result, &expr.Reason, &created, &bindings)
 		if err != nil {
 			return nil, fmt.Errorf("can't get all expressions: %w", err)
 		}
+		expr.CreatedAt = createdAt(created)
+		if expr.Bindings, err = decodeBindings(bindings); err != nil {
+			return nil, fmt.Errorf("can't get all expressions: %w", err)
+		}
 		ans = append(ans, expr)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("can't get all expressions: %w", err)
+	}
 
 	return ans, nil
 }
 
+// likeEscaper - экранирует спецсимволы LIKE, чтобы поиск шел по подстроке как есть
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// encodeBindings - значения переменных выражения для колонки bindings, пусто без переменных
+func encodeBindings(bindings map[string]float64) (string, error) {
+	if len(bindings) == 0 {
+		return "", nil
+	}
+	b, err := json.Marshal(bindings)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+func decodeBindings(raw string) (map[string]float64, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	var bindings map[string]float64
+	if err := json.Unmarshal([]byte(raw), &bindings); err != nil {
+		return nil, fmt.Errorf("invalid bindings: %w", err)
+	}
+	return bindings, nil
+}
+
+// createdAt - время создания выражения, nil для выражений, сохраненных без него
+func createdAt(ms int64) *time.Time {
+	if ms == 0 {
+		return nil
+	}
+	t := time.UnixMilli(ms).UTC()
+	return &t
+}
+
+func (s *AuthStorage) SaveRefreshToken(ctx context.Context, rt models.RefreshToken) error {
+	q := `INSERT INTO refresh_tokens (token_hash, user_id, family_id, expires_at) VALUES (?, ?, ?, ?)`
+
+	if _, err := s.db.ExecContext(ctx, q, rt.Hash, rt.UserID, rt.FamilyID, rt.ExpiresAt.Unix()); err != nil {
+		return fmt.Errorf("can't save refresh token: %w", err)
+	}
+
+	return nil
+}
+
+func (s *AuthStorage) GetRefreshToken(ctx context.Context, hash string) (models.RefreshToken, error) {
+	q := `SELECT r.token_hash, r.user_id, u.login, r.family_id, r.expires_at, r.used, r.revoked
+	FROM refresh_tokens r JOIN users u ON u.user_id = r.user_id
+	WHERE r.token_hash = ?`
+
+	var rt models.RefreshToken
+	var expiresAt int64
+	err := s.db.QueryRowContext(ctx, q, hash).Scan(&rt.Hash, &rt.UserID, &rt.Login, &rt.FamilyID, &expiresAt, &rt.Used, &rt.Revoked)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.RefreshToken{}, storage.ErrRefreshTokenNotFound
+	}
+	if err != nil {
+		return models.RefreshToken{}, fmt.Errorf("can't get refresh token: %w", err)
+	}
+	rt.ExpiresAt = time.Unix(expiresAt, 0)
+
+	return rt, nil
+}
+
+// UseRefreshToken - помечает токен использованным; false, если он уже был использован
+func (s *AuthStorage) UseRefreshToken(ctx context.Context, hash string) (bool, error) {
+	q := `UPDATE refresh_tokens SET used = 1 WHERE token_hash = ? AND used = 0`
+
+	res, err := s.db.ExecContext(ctx, q, hash)
+	if err != nil {
+		return false, fmt.Errorf("can't use refresh token: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("can't use refresh token: %w", err)
+	}
+
+	return n == 1, nil
+}
+
+func (s *AuthStorage) RevokeRefreshFamily(ctx context.Context, familyID string) error {
+	q := `UPDATE refresh_tokens SET revoked = 1 WHERE family_id = ?`
+
+	if _, err := s.db.ExecContext(ctx, q, familyID); err != nil {
+		return fmt.Errorf("can't revoke refresh tokens: %w", err)
+	}
+
+	return nil
+}
+
+// RevokeAccessToken - вносит jti токена доступа в список отозванных до истечения его срока
+func (s *AuthStorage) RevokeAccessToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	q := `INSERT OR IGNORE INTO revoked_tokens (jti, expires_at) VALUES (?, ?)`
+
+	if _, err := s.db.ExecContext(ctx, q, jti, expiresAt.Unix()); err != nil {
+		return fmt.Errorf("can't revoke access token: %w", err)
+	}
+
+	// истекшие токены больше не нужно помнить
+	q = `DELETE FROM revoked_tokens WHERE expires_at < ?`
+	if _, err := s.db.ExecContext(ctx, q, time.Now().Unix()); err != nil {
+		return fmt.Errorf("can't clean revoked tokens: %w", err)
+	}
+
+	return nil
+}
+
+func (s *AuthStorage) IsAccessTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	q := `SELECT 1 FROM revoked_tokens WHERE jti = ?`
+
+	var one int
+	err := s.db.QueryRowContext(ctx, q, jti).Scan(&one)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("can't check revoked token: %w", err)
+	}
+
+	return true, nil
+}
+
 type InitStorage struct {
 	db *sql.DB
 }
@@ -218,32 +893,211 @@ func (s *InitStorage) Init(ctx context.Context) error {
     polish_expr TEXT,
     status TEXT DEFAULT 'computing',
     result DOUBLE DEFAULT 0.0,
+    reason TEXT DEFAULT '',
     user_id INTEGER,
+    created_at INTEGER DEFAULT 0,
+    bindings TEXT DEFAULT '',
+    sweep_id INTEGER DEFAULT 0,
     FOREIGN KEY (user_id) REFERENCES users (user_id)
 	);`
 
-	if _, err := s.db.ExecContext(ctx, usersTable); err != nil {
-		return err
+	exprUserIndex := `CREATE INDEX IF NOT EXISTS expressions_user ON expressions (user_id, expr_id);`
+
+	exprSweepIndex := `CREATE INDEX IF NOT EXISTS expressions_sweep ON expressions (sweep_id, expr_id);`
+
+	exprUserStatusIndex := `CREATE INDEX IF NOT EXISTS expressions_user_status ON expressions (user_id, status, expr_id);`
+
+	refreshTable := `CREATE TABLE IF NOT EXISTS refresh_tokens (
+    token_hash TEXT PRIMARY KEY,
+    user_id INTEGER,
+    family_id TEXT,
+    expires_at INTEGER,
+    used INTEGER DEFAULT 0,
+    revoked INTEGER DEFAULT 0,
+    FOREIGN KEY (user_id) REFERENCES users (user_id)
+	);`
+
+	refreshFamilyIndex := `CREATE INDEX IF NOT EXISTS refresh_tokens_family ON refresh_tokens (family_id);`
+
+	revokedTable := `CREATE TABLE IF NOT EXISTS revoked_tokens (
+    jti TEXT PRIMARY KEY,
+    expires_at INTEGER
+	);`
+
+	tasksTable := `CREATE TABLE IF NOT EXISTS tasks (
+    expr_id INTEGER,
+    node_id INTEGER,
+    op TEXT,
+    pos INTEGER DEFAULT -1,
+    parent_id INTEGER DEFAULT 0,
+    arg_idx INTEGER DEFAULT 0,
+    argc INTEGER DEFAULT 2,
+    arg1 DOUBLE,
+    arg2 DOUBLE,
+    extra_args TEXT DEFAULT '',
+    status TEXT DEFAULT 'pending',
+    agent_id TEXT DEFAULT '',
+    attempt INTEGER DEFAULT 0,
+    lease_token TEXT DEFAULT '',
+    result DOUBLE,
+    PRIMARY KEY (expr_id, node_id),
+    FOREIGN KEY (expr_id) REFERENCES expressions (expr_id)
+	);`
+
+	settingsTable := `CREATE TABLE IF NOT EXISTS user_settings (
+    user_id INTEGER PRIMARY KEY,
+    callback_url TEXT DEFAULT '',
+    FOREIGN KEY (user_id) REFERENCES users (user_id)
+	);`
+
+	callbacksTable := `CREATE TABLE IF NOT EXISTS expr_callbacks (
+    expr_id INTEGER PRIMARY KEY,
+    url TEXT,
+    FOREIGN KEY (expr_id) REFERENCES expressions (expr_id)
+	);`
+
+	outboxTable := `CREATE TABLE IF NOT EXISTS webhook_outbox (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    expr_id INTEGER,
+    url TEXT,
+    event TEXT,
+    payload TEXT,
+    status TEXT DEFAULT 'pending',
+    attempts INTEGER DEFAULT 0,
+    next_attempt_at INTEGER,
+    created_at INTEGER,
+    FOREIGN KEY (expr_id) REFERENCES expressions (expr_id)
+	);`
+
+	outboxDueIndex := `CREATE INDEX IF NOT EXISTS webhook_outbox_due ON webhook_outbox (status, next_attempt_at);`
+
+	attemptsTable := `CREATE TABLE IF NOT EXISTS webhook_attempts (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    webhook_id INTEGER,
+    attempt INTEGER,
+    at INTEGER,
+    status_code INTEGER DEFAULT 0,
+    error TEXT DEFAULT '',
+    duration_ms INTEGER,
+    FOREIGN KEY (webhook_id) REFERENCES webhook_outbox (id)
+	);`
+
+	attemptsIndex := `CREATE INDEX IF NOT EXISTS webhook_attempts_webhook ON webhook_attempts (webhook_id);`
+
+	idempotencyTable := `CREATE TABLE IF NOT EXISTS idempotency_keys (
+    user_id INTEGER,
+    key TEXT,
+    fingerprint TEXT,
+    expr_id INTEGER,
+    created_at INTEGER,
+    PRIMARY KEY (user_id, key),
+    FOREIGN KEY (user_id) REFERENCES users (user_id)
+	);`
+
+	idempotencyCreatedIndex := `CREATE INDEX IF NOT EXISTS idempotency_keys_created ON idempotency_keys (created_at);`
+
+	sweepsTable := `CREATE TABLE IF NOT EXISTS sweeps (
+    sweep_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER,
+    expr TEXT,
+    bindings TEXT DEFAULT '',
+    params TEXT,
+    total INTEGER,
+    created_at INTEGER,
+    FOREIGN KEY (user_id) REFERENCES users (user_id)
+	);`
+
+	for _, q := range []string{
+		usersTable, exprTable, exprUserIndex, exprUserStatusIndex, refreshTable, refreshFamilyIndex, revokedTable, tasksTable,
+		settingsTable, callbacksTable, outboxTable, outboxDueIndex, attemptsTable, attemptsIndex,
+		idempotencyTable, idempotencyCreatedIndex, sweepsTable,
+	} {
+		if _, err := s.db.ExecContext(ctx, q); err != nil {
+			return err
+		}
 	}
 
-	if _, err := s.db.ExecContext(ctx, exprTable); err != nil {
+	// колонки, появившиеся после создания таблиц в существующих бд
+	if err := s.addColumn(ctx, "expressions", "reason", "TEXT DEFAULT ''"); err != nil {
+		return err
+	}
+	if err := s.addColumn(ctx, "expressions", "created_at", "INTEGER DEFAULT 0"); err != nil {
+		return err
+	}
+	if err := s.addColumn(ctx, "tasks", "extra_args", "TEXT DEFAULT ''"); err != nil {
+		return err
+	}
+	if err := s.addColumn(ctx, "tasks", "pos", "INTEGER DEFAULT -1"); err != nil {
+		return err
+	}
+	if err := s.addColumn(ctx, "expressions", "bindings", "TEXT DEFAULT ''"); err != nil {
+		return err
+	}
+	if err := s.addColumn(ctx, "expressions", "sweep_id", "INTEGER DEFAULT 0"); err != nil {
+		return err
+	}
+	// индекс создается после колонки, которой в старых бд еще нет
+	if _, err := s.db.ExecContext(ctx, exprSweepIndex); err != nil {
 		return err
 	}
 
 	return nil
 }
 
+// addColumn - добавляет колонку в существующую таблицу, если ее еще нет
+func (s *InitStorage) addColumn(ctx context.Context, table, column, def string) error {
+	rows, err := s.db.QueryContext(ctx, `SELECT name FROM pragma_table_info(?)`, table)
+	if err != nil {
+		return fmt.Errorf("can't get columns of %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return fmt.Errorf("can't get columns of %s: %w", table, err)
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("can't get columns of %s: %w", table, err)
+	}
+
+	if _, err := s.db.ExecContext(ctx, fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s`, table, column, def)); err != nil {
+		return fmt.Errorf("can't add column %s.%s: %w", table, column, err)
+	}
+	return nil
+}
+
 func (s *InitStorage) Drop(ctx context.Context) error {
 	usersTable := `DROP TABLE IF EXISTS users;`
 
 	exprTable := `DROP TABLE IF EXISTS expressions;`
 
-	if _, err := s.db.ExecContext(ctx, exprTable); err != nil {
-		return err
-	}
+	refreshTable := `DROP TABLE IF EXISTS refresh_tokens;`
 
-	if _, err := s.db.ExecContext(ctx, usersTable); err != nil {
-		return err
+	revokedTable := `DROP TABLE IF EXISTS revoked_tokens;`
+
+	tasksTable := `DROP TABLE IF EXISTS tasks;`
+
+	settingsTable := `DROP TABLE IF EXISTS user_settings;`
+
+	callbacksTable := `DROP TABLE IF EXISTS expr_callbacks;`
+
+	outboxTable := `DROP TABLE IF EXISTS webhook_outbox;`
+
+	attemptsTable := `DROP TABLE IF EXISTS webhook_attempts;`
+
+	idempotencyTable := `DROP TABLE IF EXISTS idempotency_keys;`
+
+	sweepsTable := `DROP TABLE IF EXISTS sweeps;`
+
+	for _, q := range []string{sweepsTable, idempotencyTable, attemptsTable, outboxTable, callbacksTable, settingsTable, tasksTable, revokedTable, refreshTable, exprTable, usersTable} {
+		if _, err := s.db.ExecContext(ctx, q); err != nil {
+			return err
+		}
 	}
 
 	return nil
@@ -0,0 +1,251 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kms-qwe/DAEC/internal/app/auth"
+	"github.com/kms-qwe/DAEC/internal/domain/models"
+	"github.com/kms-qwe/DAEC/internal/storage"
+)
+
+// initDB - путь к созданной бд во временном каталоге
+func initDB(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "daec.db")
+	s, err := NewInitStorage(path)
+	if err != nil {
+		t.Fatalf("NewInitStorage: %v", err)
+	}
+	defer s.db.Close()
+	if err := s.Init(context.Background()); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	return path
+}
+
+func newAuthStorage(t *testing.T) *AuthStorage {
+	t.Helper()
+	s, err := NewAuthStorage(initDB(t))
+	if err != nil {
+		t.Fatalf("NewAuthStorage: %v", err)
+	}
+	t.Cleanup(func() { s.db.Close() })
+	return s
+}
+
+func TestInitMigratesReason(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "daec.db")
+	s, err := NewInitStorage(path)
+	if err != nil {
+		t.Fatalf("NewInitStorage: %v", err)
+	}
+	defer s.db.Close()
+
+	// таблица выражений в том виде, в каком ее создавали до появления reason
+	old := `CREATE TABLE expressions (
+    expr_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    expr TEXT,
+    polish_expr TEXT,
+    status TEXT DEFAULT 'computing',
+    result DOUBLE DEFAULT 0.0,
+    user_id INTEGER
+	);`
+	if _, err := s.db.ExecContext(ctx, old); err != nil {
+		t.Fatalf("create old table: %v", err)
+	}
+	if _, err := s.db.ExecContext(ctx, `INSERT INTO expressions (expr, polish_expr, user_id) VALUES ('1+1', '1 1 +', 1)`); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+
+	// повторный Init не должен падать на уже добавленной колонке
+	for i := 0; i < 2; i++ {
+		if err := s.Init(ctx); err != nil {
+			t.Fatalf("Init #%d: %v", i+1, err)
+		}
+	}
+
+	var reason string
+	if err := s.db.QueryRowContext(ctx, `SELECT reason FROM expressions WHERE expr_id = 1`).Scan(&reason); err != nil {
+		t.Fatalf("select reason: %v", err)
+	}
+	if reason != "" {
+		t.Errorf("reason = %q, want empty", reason)
+	}
+
+	orch, err := NewOrchStorage(path)
+	if err != nil {
+		t.Fatalf("NewOrchStorage: %v", err)
+	}
+	if err := orch.FailExpr(ctx, 1, "division_by_zero: 1 / 0"); err != nil {
+		t.Errorf("FailExpr on a migrated table: %v", err)
+	}
+}
+
+func TestRefreshTokenRotation(t *testing.T) {
+	ctx := context.Background()
+	s := newAuthStorage(t)
+	userID, err := s.SaveNewUsr(ctx, auth.User{Login: "user", Password: "hash"})
+	if err != nil {
+		t.Fatalf("SaveNewUsr: %v", err)
+	}
+
+	expires := time.Now().Add(time.Hour)
+	for _, rt := range []models.RefreshToken{
+		{Hash: "a1", UserID: userID, FamilyID: "a", ExpiresAt: expires},
+		{Hash: "a2", UserID: userID, FamilyID: "a", ExpiresAt: expires},
+		{Hash: "b1", UserID: userID, FamilyID: "b", ExpiresAt: expires},
+	} {
+		if err := s.SaveRefreshToken(ctx, rt); err != nil {
+			t.Fatalf("SaveRefreshToken(%s): %v", rt.Hash, err)
+		}
+	}
+
+	rt, err := s.GetRefreshToken(ctx, "a1")
+	if err != nil {
+		t.Fatalf("GetRefreshToken: %v", err)
+	}
+	if rt.UserID != userID || rt.Login != "user" || rt.FamilyID != "a" || rt.Used || rt.Revoked {
+		t.Fatalf("GetRefreshToken = %+v", rt)
+	}
+	if rt.ExpiresAt.Unix() != expires.Unix() {
+		t.Errorf("ExpiresAt = %v, want %v", rt.ExpiresAt, expires)
+	}
+
+	// токен одноразовый: второе использование не проходит
+	if fresh, err := s.UseRefreshToken(ctx, "a1"); err != nil || !fresh {
+		t.Fatalf("first UseRefreshToken = %v, %v; want true", fresh, err)
+	}
+	if fresh, err := s.UseRefreshToken(ctx, "a1"); err != nil || fresh {
+		t.Fatalf("second UseRefreshToken = %v, %v; want false", fresh, err)
+	}
+
+	if err := s.RevokeRefreshFamily(ctx, "a"); err != nil {
+		t.Fatalf("RevokeRefreshFamily: %v", err)
+	}
+	for hash, revoked := range map[string]bool{"a1": true, "a2": true, "b1": false} {
+		rt, err := s.GetRefreshToken(ctx, hash)
+		if err != nil {
+			t.Fatalf("GetRefreshToken(%s): %v", hash, err)
+		}
+		if rt.Revoked != revoked {
+			t.Errorf("%s revoked = %v, want %v", hash, rt.Revoked, revoked)
+		}
+	}
+
+	if _, err := s.GetRefreshToken(ctx, "missing"); !errors.Is(err, storage.ErrRefreshTokenNotFound) {
+		t.Errorf("GetRefreshToken(missing) err = %v, want ErrRefreshTokenNotFound", err)
+	}
+}
+
+func TestRevokeAccessToken(t *testing.T) {
+	ctx := context.Background()
+	s := newAuthStorage(t)
+
+	if revoked, err := s.IsAccessTokenRevoked(ctx, "jti"); err != nil || revoked {
+		t.Fatalf("IsAccessTokenRevoked before = %v, %v; want false", revoked, err)
+	}
+	if err := s.RevokeAccessToken(ctx, "jti", time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("RevokeAccessToken: %v", err)
+	}
+	// повторный отзыв не ошибка
+	if err := s.RevokeAccessToken(ctx, "jti", time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("second RevokeAccessToken: %v", err)
+	}
+	if revoked, err := s.IsAccessTokenRevoked(ctx, "jti"); err != nil || !revoked {
+		t.Fatalf("IsAccessTokenRevoked after = %v, %v; want true", revoked, err)
+	}
+
+	// истекшие отзывы вычищаются при следующем отзыве
+	if err := s.RevokeAccessToken(ctx, "old", time.Now().Add(-time.Hour)); err != nil {
+		t.Fatalf("RevokeAccessToken(old): %v", err)
+	}
+	if revoked, err := s.IsAccessTokenRevoked(ctx, "old"); err != nil || revoked {
+		t.Errorf("expired IsAccessTokenRevoked = %v, %v; want false", revoked, err)
+	}
+}
+
+func TestSaveNewExprsAtomic(t *testing.T) {
+	ctx := context.Background()
+	s := newAuthStorage(t)
+	userID, err := s.SaveNewUsr(ctx, auth.User{Login: "user", Password: "hash"})
+	if err != nil {
+		t.Fatalf("SaveNewUsr: %v", err)
+	}
+
+	// вставка третьего выражения пакета падает уже в бд
+	trigger := `CREATE TRIGGER fail_insert BEFORE INSERT ON expressions
+	WHEN NEW.expr = 'boom' BEGIN SELECT RAISE(ABORT, 'boom'); END`
+	if _, err := s.db.ExecContext(ctx, trigger); err != nil {
+		t.Fatalf("create trigger: %v", err)
+	}
+
+	exprs := []auth.NewExpr{
+		{Expr: "1 + 1", PolishExpr: "1 1 +", CallbackURL: "https://example.com/hook"},
+		{Expr: "2 * 2", PolishExpr: "2 2 *"},
+		{Expr: "boom", PolishExpr: "0"},
+	}
+	if _, err := s.SaveNewExprs(ctx, userID, exprs); err == nil {
+		t.Fatal("SaveNewExprs succeeded")
+	}
+
+	for _, table := range []string{"expressions", "expr_callbacks"} {
+		var n int
+		if err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM `+table).Scan(&n); err != nil {
+			t.Fatalf("count %s: %v", table, err)
+		}
+		if n != 0 {
+			t.Errorf("%s has %d rows after a failed batch, want 0", table, n)
+		}
+	}
+
+	ids, err := s.SaveNewExprs(ctx, userID, exprs[:2])
+	if err != nil {
+		t.Fatalf("SaveNewExprs: %v", err)
+	}
+	if len(ids) != 2 || ids[1] != ids[0]+1 {
+		t.Errorf("ids = %v, want two consecutive ids", ids)
+	}
+}
+
+func TestTasksPos(t *testing.T) {
+	ctx := context.Background()
+	path := initDB(t)
+	s, err := NewOrchStorage(path)
+	if err != nil {
+		t.Fatalf("NewOrchStorage: %v", err)
+	}
+	defer s.db.Close()
+
+	two := 2.0
+	tasks := []models.Task{
+		{ExprID: 1, NodeID: 1, Op: "+", Pos: -1, Args: []*float64{&two, nil}, Status: models.TaskPending},
+		{ExprID: 1, NodeID: 2, Op: "sqrt", Pos: 4, ParentID: 1, ArgIdx: 1, Args: []*float64{&two}, Status: models.TaskPending},
+	}
+	if err := s.SaveTasks(ctx, tasks); err != nil {
+		t.Fatalf("SaveTasks: %v", err)
+	}
+	// задача, сохраненная до появления колонки pos
+	if _, err := s.db.ExecContext(ctx, `INSERT INTO tasks (expr_id, node_id, op, argc, arg1) VALUES (2, 1, 'abs', 1, 1)`); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+
+	for exprID, want := range map[int64][]int{1: {-1, 4}, 2: {-1}} {
+		rows, err := s.GetTasks(ctx, exprID)
+		if err != nil {
+			t.Fatalf("GetTasks(%d): %v", exprID, err)
+		}
+		if len(rows) != len(want) {
+			t.Fatalf("GetTasks(%d) = %d tasks, want %d", exprID, len(rows), len(want))
+		}
+		for i, r := range rows {
+			if r.Pos != want[i] {
+				t.Errorf("expr %d task %d pos = %d, want %d", exprID, r.NodeID, r.Pos, want[i])
+			}
+		}
+	}
+}
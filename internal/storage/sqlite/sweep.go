@@ -0,0 +1,122 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/kms-qwe/DAEC/internal/app/auth"
+	"github.com/kms-qwe/DAEC/internal/storage"
+)
+
+// SaveSweep - сохраняет перебор и его выражения одной транзакцией
+func (s *AuthStorage) SaveSweep(ctx context.Context, userID int64, sweep auth.Sweep, exprs []auth.NewExpr) (int64, error) {
+	q := `INSERT INTO sweeps (user_id, expr, bindings, params, total, created_at) VALUES (?, ?, ?, ?, ?, ?)`
+
+	bindings, err := encodeBindings(sweep.Bindings)
+	if err != nil {
+		return 0, fmt.Errorf("can't save sweep: %w", err)
+	}
+	params, err := json.Marshal(sweep.Params)
+	if err != nil {
+		return 0, fmt.Errorf("can't save sweep: %w", err)
+	}
+
+	var id int64
+	err = withTx(ctx, s.db, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, q, userID, sweep.Expr, bindings, string(params), sweep.Total, time.Now().UnixMilli())
+		if err != nil {
+			return fmt.Errorf("can't save sweep: %w", err)
+		}
+		if id, err = res.LastInsertId(); err != nil {
+			return fmt.Errorf("can't save sweep: %w", err)
+		}
+		_, err = insertExprs(ctx, tx, userID, id, exprs)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// GetSweep - перебор пользователя и число его выражений по статусам
+func (s *AuthStorage) GetSweep(ctx context.Context, sweepID int64, userID int64) (auth.Sweep, error) {
+	q := `SELECT sweep_id, expr, bindings, params, total, created_at FROM sweeps WHERE sweep_id = ? AND user_id = ?`
+	qProgress := `SELECT status, COUNT(*) FROM expressions WHERE sweep_id = ? GROUP BY status`
+
+	var ans auth.Sweep
+	var bindings, params string
+	var created int64
+	err := s.db.QueryRowContext(ctx, q, sweepID, userID).Scan(&ans.ID, &ans.Expr, &bindings, &params, &ans.Total, &created)
+	if errors.Is(err, sql.ErrNoRows) {
+		return auth.Sweep{}, storage.ErrSweepNotFound
+	}
+	if err != nil {
+		return auth.Sweep{}, fmt.Errorf("can't get sweep: %w", err)
+	}
+	ans.CreatedAt = time.UnixMilli(created).UTC()
+	if ans.Bindings, err = decodeBindings(bindings); err != nil {
+		return auth.Sweep{}, fmt.Errorf("can't get sweep: %w", err)
+	}
+	if err := json.Unmarshal([]byte(params), &ans.Params); err != nil {
+		return auth.Sweep{}, fmt.Errorf("can't get sweep: invalid params: %w", err)
+	}
+
+	rows, err := s.db.QueryContext(ctx, qProgress, sweepID)
+	if err != nil {
+		return auth.Sweep{}, fmt.Errorf("can't get sweep progress: %w", err)
+	}
+	defer rows.Close()
+
+	ans.Progress = map[string]int{}
+	for rows.Next() {
+		var status string
+		var n int
+		if err := rows.Scan(&status, &n); err != nil {
+			return auth.Sweep{}, fmt.Errorf("can't get sweep progress: %w", err)
+		}
+		ans.Progress[status] = n
+	}
+	if err := rows.Err(); err != nil {
+		return auth.Sweep{}, fmt.Errorf("can't get sweep progress: %w", err)
+	}
+
+	return ans, nil
+}
+
+// GetSweepExprs - выражения перебора в порядке перебора значений параметров
+func (s *AuthStorage) GetSweepExprs(ctx context.Context, sweepID int64) ([]auth.Expr, error) {
+	q := `SELECT expr_id, expr, status, result, reason, created_at, bindings FROM expressions
+	WHERE sweep_id = ? ORDER BY expr_id`
+
+	rows, err := s.db.QueryContext(ctx, q, sweepID)
+	if err != nil {
+		return nil, fmt.Errorf("can't get sweep expressions: %w", err)
+	}
+	defer rows.Close()
+
+	var ans []auth.Expr
+	for rows.Next() {
+		expr := auth.Expr{}
+		var created int64
+		var bindings string
+		if err := rows.Scan(&expr.Id, &expr.Exp, &expr.Status, &expr.Result, &expr.Reason, &created, &bindings); err != nil {
+			return nil, fmt.Errorf("can't get sweep expressions: %w", err)
+		}
+		expr.CreatedAt = createdAt(created)
+		if expr.Bindings, err = decodeBindings(bindings); err != nil {
+			return nil, fmt.Errorf("can't get sweep expressions: %w", err)
+		}
+		ans = append(ans, expr)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("can't get sweep expressions: %w", err)
+	}
+
+	return ans, nil
+}
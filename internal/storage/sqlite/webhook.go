@@ -0,0 +1,173 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/kms-qwe/DAEC/internal/app/auth"
+	"github.com/kms-qwe/DAEC/internal/domain/models"
+)
+
+// enqueueWebhook - ставит в outbox вебхук о завершении выражения, если для него задан адрес
+func enqueueWebhook(ctx context.Context, tx *sql.Tx, ev models.ExprEvent) error {
+	var url string
+	err := tx.QueryRowContext(ctx, `SELECT url FROM expr_callbacks WHERE expr_id = ?`, ev.ID).Scan(&url)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("can't get expr callback: %w", err)
+	}
+
+	now := time.Now()
+	ev.FinishedAt = now.UTC()
+	payload, err := json.Marshal(ev)
+	if err != nil {
+		return fmt.Errorf("can't marshal webhook payload: %w", err)
+	}
+
+	q := `INSERT INTO webhook_outbox (expr_id, url, event, payload, next_attempt_at, created_at) VALUES (?, ?, ?, ?, ?, ?)`
+	if _, err := tx.ExecContext(ctx, q, ev.ID, url, ev.Event, string(payload), now.UnixMilli(), now.UnixMilli()); err != nil {
+		return fmt.Errorf("can't enqueue webhook: %w", err)
+	}
+
+	return nil
+}
+
+// DueWebhooks - до limit недоставленных вебхуков, время очередной попытки которых наступило
+func (s *OrchStorage) DueWebhooks(ctx context.Context, now time.Time, limit int) ([]models.Webhook, error) {
+	q := `SELECT id, expr_id, url, event, payload, status, attempts, next_attempt_at, created_at
+	FROM webhook_outbox WHERE status = ? AND next_attempt_at <= ?
+	ORDER BY next_attempt_at LIMIT ?`
+
+	rows, err := s.db.QueryContext(ctx, q, models.WebhookPending, now.UnixMilli(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("can't get due webhooks: %w", err)
+	}
+	defer rows.Close()
+
+	var ans []models.Webhook
+	for rows.Next() {
+		var wh models.Webhook
+		var payload string
+		var next, created int64
+		if err := rows.Scan(&wh.ID, &wh.ExprID, &wh.URL, &wh.Event, &payload, &wh.Status, &wh.Attempts, &next, &created); err != nil {
+			return nil, fmt.Errorf("can't get due webhooks: %w", err)
+		}
+		wh.Payload = []byte(payload)
+		wh.NextAttemptAt = time.UnixMilli(next)
+		wh.CreatedAt = time.UnixMilli(created)
+		ans = append(ans, wh)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("can't get due webhooks: %w", err)
+	}
+
+	return ans, nil
+}
+
+// SaveWebhookAttempt - записывает попытку доставки в журнал и новое состояние вебхука
+func (s *OrchStorage) SaveWebhookAttempt(ctx context.Context, wh models.Webhook, a models.WebhookAttempt) error {
+	qAttempt := `INSERT INTO webhook_attempts (webhook_id, attempt, at, status_code, error, duration_ms) VALUES (?, ?, ?, ?, ?, ?)`
+	qOutbox := `UPDATE webhook_outbox SET status = ?, attempts = ?, next_attempt_at = ? WHERE id = ?`
+
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, qAttempt, a.WebhookID, a.Attempt, a.At.UnixMilli(), a.StatusCode, a.Error, a.Duration.Milliseconds())
+		if err != nil {
+			return fmt.Errorf("can't save webhook attempt: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, qOutbox, wh.Status, wh.Attempts, wh.NextAttemptAt.UnixMilli(), wh.ID); err != nil {
+			return fmt.Errorf("can't update webhook: %w", err)
+		}
+		return nil
+	})
+}
+
+// GetSettings - настройки пользователя, значения по умолчанию если он их не менял
+func (s *AuthStorage) GetSettings(ctx context.Context, userID int64) (auth.Settings, error) {
+	q := `SELECT callback_url FROM user_settings WHERE user_id = ?`
+
+	var ans auth.Settings
+	err := s.db.QueryRowContext(ctx, q, userID).Scan(&ans.CallbackURL)
+	if errors.Is(err, sql.ErrNoRows) {
+		return auth.Settings{}, nil
+	}
+	if err != nil {
+		return auth.Settings{}, fmt.Errorf("can't get settings: %w", err)
+	}
+
+	return ans, nil
+}
+
+func (s *AuthStorage) SaveSettings(ctx context.Context, userID int64, settings auth.Settings) error {
+	q := `INSERT INTO user_settings (user_id, callback_url) VALUES (?, ?)
+	ON CONFLICT (user_id) DO UPDATE SET callback_url = excluded.callback_url`
+
+	if _, err := s.db.ExecContext(ctx, q, userID, settings.CallbackURL); err != nil {
+		return fmt.Errorf("can't save settings: %w", err)
+	}
+
+	return nil
+}
+
+// GetWebhooks - вебхуки выражения с журналом попыток доставки
+func (s *AuthStorage) GetWebhooks(ctx context.Context, exprID int64) ([]auth.WebhookDelivery, error) {
+	q := `SELECT id, event, url, status, next_attempt_at, created_at FROM webhook_outbox WHERE expr_id = ? ORDER BY id`
+	qAttempts := `SELECT a.webhook_id, a.attempt, a.at, a.status_code, a.error, a.duration_ms
+	FROM webhook_attempts a JOIN webhook_outbox o ON o.id = a.webhook_id
+	WHERE o.expr_id = ? ORDER BY a.id`
+
+	rows, err := s.db.QueryContext(ctx, q, exprID)
+	if err != nil {
+		return nil, fmt.Errorf("can't get webhooks: %w", err)
+	}
+	defer rows.Close()
+
+	ans := []auth.WebhookDelivery{}
+	byID := map[int64]int{}
+	for rows.Next() {
+		var d auth.WebhookDelivery
+		var next, created int64
+		if err := rows.Scan(&d.ID, &d.Event, &d.URL, &d.Status, &next, &created); err != nil {
+			return nil, fmt.Errorf("can't get webhooks: %w", err)
+		}
+		if d.Status == models.WebhookPending {
+			t := time.UnixMilli(next).UTC()
+			d.NextAttemptAt = &t
+		}
+		d.CreatedAt = time.UnixMilli(created).UTC()
+		d.Attempts = []auth.WebhookAttemptLog{}
+		byID[d.ID] = len(ans)
+		ans = append(ans, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("can't get webhooks: %w", err)
+	}
+
+	rows, err = s.db.QueryContext(ctx, qAttempts, exprID)
+	if err != nil {
+		return nil, fmt.Errorf("can't get webhook attempts: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var a auth.WebhookAttemptLog
+		var webhookID, at int64
+		if err := rows.Scan(&webhookID, &a.Attempt, &at, &a.StatusCode, &a.Error, &a.DurationMs); err != nil {
+			return nil, fmt.Errorf("can't get webhook attempts: %w", err)
+		}
+		a.At = time.UnixMilli(at).UTC()
+		if i, ok := byID[webhookID]; ok {
+			ans[i].Attempts = append(ans[i].Attempts, a)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("can't get webhook attempts: %w", err)
+	}
+
+	return ans, nil
+}
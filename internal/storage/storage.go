@@ -3,5 +3,10 @@ package storage
 import "errors"
 
 var (
-	ErrExprNotFound = errors.New("expression not found")
+	ErrUserNotFound         = errors.New("user not found")
+	ErrExprNotFound         = errors.New("expression not found")
+	ErrRefreshTokenNotFound = errors.New("refresh token not found")
+	ErrExprFinished         = errors.New("expression already finished")
+	ErrIdempotencyKeyReused = errors.New("idempotency key reused with different request")
+	ErrSweepNotFound        = errors.New("sweep not found")
 )
@@ -0,0 +1,174 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/kms-qwe/DAEC/internal/config"
+	"github.com/kms-qwe/DAEC/internal/domain/models"
+	"github.com/kms-qwe/DAEC/internal/lib/logger/sl"
+)
+
+// Заголовки запроса вебхука
+const (
+	HeaderSignature = "X-DAEC-Signature"
+	HeaderEvent     = "X-DAEC-Event"
+	HeaderDelivery  = "X-DAEC-Delivery"
+)
+
+// batchSize - сколько вебхуков берется из outbox за один опрос
+const batchSize = 20
+
+type Storage interface {
+	DueWebhooks(context.Context, time.Time, int) ([]models.Webhook, error)
+	SaveWebhookAttempt(context.Context, models.Webhook, models.WebhookAttempt) error
+}
+
+// Dispatcher - доставляет вебхуки из outbox. Неудачная попытка (нет ответа
+// или ответ не 2xx) повторяется с экспоненциальной задержкой, после
+// MaxAttempts попыток вебхук помечается failed
+type Dispatcher struct {
+	log     *slog.Logger
+	storage Storage
+	client  *http.Client
+	cfg     config.WebhookConfig
+}
+
+func New(log *slog.Logger, storage Storage, cfg config.WebhookConfig) *Dispatcher {
+	return &Dispatcher{
+		log:     log,
+		storage: storage,
+		client:  &http.Client{Timeout: cfg.Timeout},
+		cfg:     cfg,
+	}
+}
+
+// Run - опрашивает outbox, пока не отменен ctx. Отмена ctx прерывает начатую
+// доставку: прерванная попытка не записывается, вебхук остается в outbox и
+// будет отправлен после перезапуска
+func (d *Dispatcher) Run(ctx context.Context) {
+	const op = "webhook.Run"
+	log := d.log.With(slog.String("op", op))
+	log.Info("webhook dispatcher starts")
+
+	ticker := time.NewTicker(d.cfg.PollInterval)
+	defer ticker.Stop()
+	for {
+		due, err := d.storage.DueWebhooks(ctx, time.Now(), batchSize)
+		if err != nil && ctx.Err() == nil {
+			log.Error("не удалось получить вебхуки из outbox", sl.Err(err))
+		}
+		for _, wh := range due {
+			if ctx.Err() != nil {
+				break
+			}
+			d.deliver(ctx, log, wh)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			log.Info("webhook dispatcher stopped")
+			return
+		}
+	}
+}
+
+// deliver - одна попытка доставки вебхука, результат записывается в журнал
+func (d *Dispatcher) deliver(ctx context.Context, log *slog.Logger, wh models.Webhook) {
+	log = log.With(
+		slog.Int64("webhook", wh.ID),
+		slog.Int64("expr_id", wh.ExprID),
+		slog.String("url", wh.URL),
+	)
+
+	wh.Attempts++
+	attempt := models.WebhookAttempt{WebhookID: wh.ID, Attempt: wh.Attempts, At: time.Now()}
+	status, err := d.post(ctx, wh, attempt.At)
+	if err != nil && ctx.Err() != nil {
+		// попытку прервала остановка, получатель мог не ответить не по своей вине
+		log.Info("доставка вебхука прервана остановкой", sl.Err(err))
+		return
+	}
+	attempt.Duration = time.Since(attempt.At)
+	attempt.StatusCode = status
+
+	switch {
+	case err == nil:
+		wh.Status = models.WebhookDelivered
+		log.Info("вебхук доставлен", slog.Int("attempt", wh.Attempts), slog.Int("status", status))
+	case wh.Attempts >= d.cfg.MaxAttempts:
+		attempt.Error = err.Error()
+		wh.Status = models.WebhookFailed
+		log.Info("вебхук не доставлен, попытки исчерпаны", slog.Int("attempt", wh.Attempts), sl.Err(err))
+	default:
+		attempt.Error = err.Error()
+		wh.NextAttemptAt = attempt.At.Add(d.backoff(wh.Attempts))
+		log.Info(
+			"вебхук не доставлен, повтор позже",
+			slog.Int("attempt", wh.Attempts),
+			slog.Time("next_attempt_at", wh.NextAttemptAt),
+			sl.Err(err),
+		)
+	}
+
+	// завершенная попытка записывается, даже если остановка пришла во время записи
+	if err := d.storage.SaveWebhookAttempt(context.WithoutCancel(ctx), wh, attempt); err != nil {
+		log.Error("не удалось сохранить попытку доставки вебхука", sl.Err(err))
+	}
+}
+
+// post - отправляет вебхук; ошибка, если ответа нет или он не 2xx
+func (d *Dispatcher) post(ctx context.Context, wh models.Webhook, now time.Time) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, wh.URL, bytes.NewReader(wh.Payload))
+	if err != nil {
+		return 0, fmt.Errorf("can't create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "DAEC-Webhook/1")
+	req.Header.Set(HeaderEvent, wh.Event)
+	req.Header.Set(HeaderDelivery, strconv.FormatInt(wh.ID, 10))
+	req.Header.Set(HeaderSignature, Sign([]byte(d.cfg.Secret), now, wh.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// backoff - задержка перед попыткой attempt+1
+func (d *Dispatcher) backoff(attempt int) time.Duration {
+	delay := d.cfg.Backoff
+	for i := 1; i < attempt && delay < d.cfg.MaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, d.cfg.MaxBackoff)
+}
+
+// Sign - подпись вебхука для заголовка X-DAEC-Signature: t=<unix>,v1=<hex>,
+// где v1 - HMAC-SHA256 от "<unix>.<тело>" на общем секрете. Время в подписи
+// позволяет получателю отбрасывать повторно отправленные старые запросы
+func Sign(secret []byte, t time.Time, body []byte) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(ts))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "t=" + ts + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,229 @@
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kms-qwe/DAEC/internal/config"
+	"github.com/kms-qwe/DAEC/internal/domain/models"
+)
+
+// fakeStorage - outbox из одного вебхука и журнал его попыток
+type fakeStorage struct {
+	mu       sync.Mutex
+	wh       models.Webhook
+	attempts []models.WebhookAttempt
+	done     chan struct{}
+}
+
+func (s *fakeStorage) DueWebhooks(_ context.Context, now time.Time, _ int) ([]models.Webhook, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.wh.Status != models.WebhookPending || s.wh.NextAttemptAt.After(now) {
+		return nil, nil
+	}
+	return []models.Webhook{s.wh}, nil
+}
+
+func (s *fakeStorage) SaveWebhookAttempt(_ context.Context, wh models.Webhook, attempt models.WebhookAttempt) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.wh = wh
+	s.attempts = append(s.attempts, attempt)
+	if wh.Status != models.WebhookPending {
+		close(s.done)
+	}
+	return nil
+}
+
+// verify - проверяет заголовок X-DAEC-Signature так, как это делает получатель
+func verify(secret, header string, body []byte) bool {
+	ts, sig, ok := strings.Cut(header, ",")
+	if !ok || !strings.HasPrefix(ts, "t=") || !strings.HasPrefix(sig, "v1=") {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strings.TrimPrefix(ts, "t=") + "."))
+	mac.Write(body)
+	want := hex.EncodeToString(mac.Sum(nil))
+	return hmac.Equal([]byte(want), []byte(strings.TrimPrefix(sig, "v1=")))
+}
+
+func TestDispatcherRetries(t *testing.T) {
+	const secret = "test-secret"
+	cfg := config.WebhookConfig{
+		Secret:       secret,
+		Timeout:      time.Second,
+		MaxAttempts:  4,
+		Backoff:      20 * time.Millisecond,
+		MaxBackoff:   40 * time.Millisecond,
+		PollInterval: 5 * time.Millisecond,
+	}
+	payload := []byte(`{"event":"expression.done","id":7,"status":"done","result":3}`)
+
+	tests := []struct {
+		name     string
+		statuses []int
+		want     string
+	}{
+		{
+			name:     "delivered after 5xx",
+			statuses: []int{http.StatusInternalServerError, http.StatusBadGateway, http.StatusOK},
+			want:     models.WebhookDelivered,
+		},
+		{
+			name:     "failed after max attempts",
+			statuses: []int{http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout},
+			want:     models.WebhookFailed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var calls int
+			var badSig []string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				mu.Lock()
+				defer mu.Unlock()
+				if !verify(secret, r.Header.Get(HeaderSignature), body) || string(body) != string(payload) {
+					badSig = append(badSig, r.Header.Get(HeaderSignature))
+				}
+				if r.Header.Get(HeaderEvent) != models.EventExprDone || r.Header.Get(HeaderDelivery) != "1" {
+					badSig = append(badSig, "headers: "+r.Header.Get(HeaderEvent)+" "+r.Header.Get(HeaderDelivery))
+				}
+				w.WriteHeader(tt.statuses[min(calls, len(tt.statuses)-1)])
+				calls++
+			}))
+			defer srv.Close()
+
+			st := &fakeStorage{
+				wh: models.Webhook{
+					ID:      1,
+					ExprID:  7,
+					URL:     srv.URL,
+					Event:   models.EventExprDone,
+					Payload: payload,
+					Status:  models.WebhookPending,
+				},
+				done: make(chan struct{}),
+			}
+			d := New(slog.New(slog.NewTextHandler(io.Discard, nil)), st, cfg)
+			ctx, cancel := context.WithCancel(context.Background())
+			stopped := make(chan struct{})
+			go func() {
+				d.Run(ctx)
+				close(stopped)
+			}()
+			select {
+			case <-st.done:
+			case <-time.After(5 * time.Second):
+				t.Fatal("webhook was not finished in time")
+			}
+			cancel()
+			<-stopped
+
+			st.mu.Lock()
+			defer st.mu.Unlock()
+			mu.Lock()
+			defer mu.Unlock()
+			if len(badSig) > 0 {
+				t.Errorf("invalid signed requests: %q", badSig)
+			}
+			if st.wh.Status != tt.want {
+				t.Errorf("status = %q, want %q", st.wh.Status, tt.want)
+			}
+			if len(st.attempts) != len(tt.statuses) || calls != len(tt.statuses) {
+				t.Fatalf("attempts = %d, requests = %d, want %d", len(st.attempts), calls, len(tt.statuses))
+			}
+			for i, a := range st.attempts {
+				if a.WebhookID != 1 || a.Attempt != i+1 || a.StatusCode != tt.statuses[i] {
+					t.Errorf("attempt %d = %+v, want status %d", i+1, a, tt.statuses[i])
+				}
+				if ok := tt.statuses[i] < 300; ok != (a.Error == "") {
+					t.Errorf("attempt %d error = %q", i+1, a.Error)
+				}
+				if i == 0 {
+					continue
+				}
+				// задержка удваивается от Backoff до MaxBackoff
+				wantGap := min(cfg.Backoff<<(i-1), cfg.MaxBackoff)
+				if gap := a.At.Sub(st.attempts[i-1].At); gap < wantGap {
+					t.Errorf("attempt %d after %v, want at least %v", i+1, gap, wantGap)
+				}
+			}
+		})
+	}
+}
+
+func TestDispatcherStopAbortsDelivery(t *testing.T) {
+	received := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		close(received)
+		// получатель отвечает дольше, чем оркестратор готов ждать при остановке
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer srv.Close()
+
+	st := &fakeStorage{
+		wh: models.Webhook{
+			ID:      1,
+			ExprID:  7,
+			URL:     srv.URL,
+			Event:   models.EventExprDone,
+			Payload: []byte(`{}`),
+			Status:  models.WebhookPending,
+		},
+		done: make(chan struct{}),
+	}
+	cfg := config.WebhookConfig{
+		Secret:       "test-secret",
+		Timeout:      10 * time.Second,
+		MaxAttempts:  3,
+		Backoff:      time.Second,
+		MaxBackoff:   time.Second,
+		PollInterval: 5 * time.Millisecond,
+	}
+	d := New(slog.New(slog.NewTextHandler(io.Discard, nil)), st, cfg)
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		d.Run(ctx)
+		close(stopped)
+	}()
+
+	select {
+	case <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook was not sent")
+	}
+	cancel()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("Run did not return after cancel")
+	}
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if len(st.attempts) != 0 {
+		t.Errorf("attempts = %+v, want the aborted attempt not recorded", st.attempts)
+	}
+	if st.wh.Status != models.WebhookPending || st.wh.Attempts != 0 {
+		t.Errorf("webhook = %+v, want pending without attempts", st.wh)
+	}
+}
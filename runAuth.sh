@@ -1,2 +1,3 @@
 #!/bin/sh
+./genkey.sh || exit 1
 go run ./cmd/auth/main.go --config=./config/local.yaml 
\ No newline at end of file